@@ -1,41 +1,144 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"time"
 
+	"github.com/akawula/DoraMatic/compliance"
+	"github.com/akawula/DoraMatic/events"
+	"github.com/akawula/DoraMatic/github/client"
 	"github.com/akawula/DoraMatic/github/organizations"
 	"github.com/akawula/DoraMatic/github/pullrequests"
 	"github.com/akawula/DoraMatic/github/repositories"
-	"github.com/akawula/DoraMatic/slack"
+	"github.com/akawula/DoraMatic/github/workflows"
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/akawula/DoraMatic/notify"
 
 	"github.com/akawula/DoraMatic/store"
 )
 
-func debug() slog.Level {
+func debug(cfg *config.Config) slog.Level {
 	level := slog.LevelInfo
-	if os.Getenv("DEBUG") == "1" {
+	if cfg.Debug {
 		level = slog.LevelDebug
 	}
 	return level
 }
 
-func logger() *slog.Logger {
+func logger(cfg *config.Config) *slog.Logger {
 	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: debug(),
+		Level: debug(cfg),
 	}))
 }
 
 func main() {
-	l := logger()
-	db := store.NewPostgres(l)
+	summaryPath := flag.String("summary-json", "", "write a JSON summary of the run (repositories, pull requests written, errors) to the file")
+	backfillOpen := flag.Bool("backfill-open", false, "fetch the pull requests again since the oldest one stored as OPEN, so the ones closed or merged since are updated")
+	repair := flag.Bool("repair", false, "fetch the pull requests with inconsistent data (orphaned rows, missing repository) again")
+	flag.Parse()
+
+	s := newSummary()
+	code := run(s, *backfillOpen, *repair)
+	if len(*summaryPath) > 0 {
+		if err := s.write(*summaryPath, code); err != nil {
+			slog.Error("can't write the run summary", "path", *summaryPath, "error", err)
+		}
+	}
+	os.Exit(code)
+}
+
+// run syncs and reports, it returns the exit code of the cronjob.
+func run(s *summary, backfillOpen bool, repair bool) int {
+	cfg, err := config.Load()
+	l := logger(cfg)
+	if err = errors.Join(err, cfg.GitHub.Validate(), cfg.Postgres.Validate()); err != nil {
+		l.Error("invalid configuration", "error", err)
+		s.fail("config", err)
+		return exitFailed
+	}
+	l.Info("configuration loaded", "config", cfg)
+
+	// the report reads the pull requests this run just wrote, a lagging replica wouldn't have them yet
+	cfg.Postgres.ReplicaHost = ""
+	db := store.NewPostgres(cfg.Postgres, l)
 	defer db.Close()
 
-	teams, err := organizations.GetTeams()
+	if err := db.CheckSchema(); err != nil {
+		l.Error("database schema is not up to date", "error", err)
+		s.fail("schema", err)
+		return exitFailed
+	}
+
+	publisher := newPublisher(cfg, l, s)
+	defer publisher.Close()
+	db.SetPublisher(publisher)
+
+	if err := sync(cfg, l, db, s, backfillOpen); err != nil {
+		s.fail("sync", err)
+		return exitFailed
+	}
+	if err := publisher.Publish([]events.Event{{Type: events.SyncCompleted, At: time.Now(), Data: map[string]interface{}{
+		"started_at":   s.StartedAt,
+		"repos_total":  s.ReposTotal,
+		"repos_ok":     s.ReposOk,
+		"repos_failed": s.ReposFailed,
+		"prs_written":  s.PRsWritten,
+	}}}); err != nil {
+		l.Error("can't publish the sync.completed event", "error", err)
+		s.fail("events", err)
+	}
+
+	checkConsistency(cfg, l, db, s, repair)
+
+	report(cfg, l, db, s)
+	l.Info("run finished", "reposOk", s.ReposOk, "reposFailed", len(s.ReposFailed), "prsWritten", s.PRsWritten, "prsSkipped", s.PRsSkipped, "errors", len(s.Errors), "githubUsage", client.UsageByStage())
+
+	return s.exitCode()
+}
+
+// newPublisher connects to the events broker, the events are for downstream systems, so the sync runs without them
+// when the broker is misconfigured or down.
+func newPublisher(cfg *config.Config, l *slog.Logger, s *summary) events.Publisher {
+	if err := cfg.Events.Validate(); err != nil {
+		l.Error("invalid events configuration, the events aren't published", "error", err)
+		s.fail("events", err)
+		return events.Noop{}
+	}
+
+	publisher, err := events.New(cfg.Events)
+	if err != nil {
+		l.Error("can't connect to the events broker, the events aren't published", "error", err)
+		s.fail("events", err)
+		return events.Noop{}
+	}
+
+	return publisher
+}
+
+// sync fetches teams, repositories and pull requests from GitHub into the DB,
+// it returns an error only when nothing could be synced, the other failures are recorded in the summary.
+func sync(cfg *config.Config, l *slog.Logger, db store.IngestStore, s *summary, backfillOpen bool) error {
+	l.Info("organizations filter", "include", cfg.GitHub.OrgsInclude, "exclude", cfg.GitHub.OrgsExclude)
+
+	// the organizations are fetched once and shared by the teams, identities and repositories stages
+	client.SetStage("organizations")
+	orgs, err := organizations.Get(cfg.GitHub)
+	if err != nil {
+		l.Error("can't fetch the organizations from github", "error", err)
+		return err
+	}
+	l.Info("organizations to sync", "orgs", orgs)
+
+	client.SetStage("teams")
+	teams, err := organizations.GetTeams(cfg.GitHub, orgs)
 	if err != nil {
 		l.Error("can't fetched teams!", "error", err)
-		return
+		return err
 	}
 
 	for name, members := range teams {
@@ -44,36 +147,250 @@ func main() {
 
 	if err = db.SaveTeams(teams); err != nil {
 		l.Error("can't save the teams into DB", "error", err)
+		s.fail("teams", err)
 	}
 
-	repos, err := repositories.Get()
+	client.SetStage("identities")
+	identities, err := organizations.GetIdentities(cfg.GitHub, orgs)
 	if err != nil {
-		l.Error("can't fetch the organizations/repositories from github", "error", err)
+		l.Warn("can't fetch the SAML identities, is the admin:org scope granted?", "error", err)
+	} else if err = db.SaveIdentities(identities); err != nil {
+		l.Error("can't save the SAML identities into DB", "error", err)
+		s.fail("identities", err)
 	}
 
+	if err = db.SaveIgnoredRepos(cfg.IgnoredRepos); err != nil {
+		l.Error("can't save the ignored repositories into DB", "error", err)
+		s.fail("ignored repositories", err)
+	}
+
+	if err = db.SaveBlockingLabels(cfg.BlockingLabels); err != nil {
+		l.Error("can't save the blocking labels into DB", "error", err)
+		s.fail("blocking labels", err)
+	}
+
+	client.SetStage("repositories")
+	repos, err := repositories.Get(cfg.GitHub, orgs)
+	if err != nil {
+		l.Error("can't fetch the repositories from github", "error", err)
+		return err
+	}
+
+	if len(repos) == 0 {
+		l.Warn("no repositories fetched, keeping the stored ones")
+	} else if err = db.SaveRepos(repos); err != nil {
+		l.Error("can't save the repositories into DB", "error", err)
+		s.fail("repositories", err)
+	} else {
+		linkRenamedRepos(cfg, l, db, s)
+		if renamed, err := db.SyncRepositoryRenames(); err != nil {
+			l.Error("can't move the history of renamed repositories", "error", err)
+			s.fail("renames", err)
+		} else {
+			l.Info("history of renamed repositories moved", "prs", renamed)
+		}
+	}
+
+	// the archived repositories are stored so their history is known, they have nothing new to sync
+	repos = slices.DeleteFunc(repos, func(repo repositories.Repository) bool { return bool(repo.IsArchived) })
+	s.ReposTotal = len(repos)
+
+	l.Info("repositories to sync", "total", len(repos))
 	max := len(repos)
 	i := 0
 	for _, repo := range repos {
 		i++
 		t := db.GetLastPRDate(string(repo.Owner.Login), string(repo.Name))
+		if backfillOpen {
+			if oldest, ok := db.GetOldestOpenPRDate(string(repo.Owner.Login), string(repo.Name)); ok && oldest.Before(t) {
+				t = oldest
+			}
+		}
 		l.Info(fmt.Sprintf("starting fetching pull requests [%d/%d]", i, max), "org", repo.Owner.Login, "repo", repo.Name, "lastPRdate", t)
-		r, err := pullrequests.Get(string(repo.Owner.Login), string(repo.Name), t, l)
+		client.SetStage("pull requests")
+		r, err := pullrequests.Get(cfg.GitHub, string(repo.Owner.Login), string(repo.Name), t, l)
 		if err != nil {
-			slog.Error("there was an error while fetching pull requests", "error", err)
-			return
+			l.Error("there was an error while fetching pull requests", "org", repo.Owner.Login, "repo", repo.Name, "error", err)
+			s.failRepo(string(repo.Owner.Login), string(repo.Name), err)
+			continue
 		}
 
-		err = db.SavePullRequest(r)
-		if err != nil {
+		result, err := db.SavePullRequest(r)
+		if !s.savedRepo(string(repo.Owner.Login), string(repo.Name), result, err) {
 			l.Error("there was a problem while saving prs to db", "error", err)
+			continue
+		}
+
+		if err = db.SaveCompliance(compliance.Evaluate(cfg.Compliance, r)); err != nil {
+			l.Error("there was a problem while saving prs compliance to db", "error", err)
+			s.fail("compliance", err)
+		}
+
+		if len(cfg.GitHub.DeployWorkflows) > 0 {
+			syncWorkflowRuns(cfg, l, db, s, string(repo.Owner.Login), string(repo.Name))
+		}
+	}
+
+	refreshOpenPRs(cfg, l, db, s, orgs)
+
+	updated, err := db.RecomputeDerivedColumns()
+	if err != nil {
+		l.Error("can't recompute derived pull request columns", "error", err)
+		s.fail("derived columns", err)
+	}
+	l.Info("derived pull request columns recomputed", "updated", updated)
+
+	return nil
+}
+
+// linkRenamedRepos asks GitHub for the ids of the repositories whose pull requests were stored under a name they had
+// before ids were tracked, the names are saved as aliases so SyncRepositoryRenames moves their history too.
+func linkRenamedRepos(cfg *config.Config, l *slog.Logger, db store.IngestStore, s *summary) {
+	unlinked, err := db.GetUnlinkedRepos()
+	if err != nil {
+		l.Error("can't fetch the repositories without id from DB", "error", err)
+		s.fail("renames", err)
+		return
+	}
+
+	client.SetStage("renames")
+	for _, repo := range unlinked {
+		id, err := repositories.GetId(cfg.GitHub, repo.Org, repo.Slug)
+		if err != nil {
+			l.Debug("can't resolve the previous repository name, it was deleted", "org", repo.Org, "repo", repo.Slug, "error", err)
+			continue
 		}
+		if err = db.SaveRepositoryAlias(id, repo.Org, repo.Slug); err != nil {
+			l.Error("can't save the repository alias into DB", "org", repo.Org, "repo", repo.Slug, "error", err)
+			s.fail("renames", err)
+		}
+	}
+}
+
+// refreshOpenPRs fetches the pull requests stored as OPEN again, the sync stops at the last merged one,
+// so the older open ones would never get their state, reviews and diff updated otherwise.
+func refreshOpenPRs(cfg *config.Config, l *slog.Logger, db store.IngestStore, s *summary, orgs []string) {
+	ids, err := db.GetOpenPRIds(orgs)
+	if err != nil {
+		l.Error("can't fetch the open pull requests from DB", "error", err)
+		s.fail("open prs", err)
+		return
+	}
+
+	client.SetStage("open pull requests")
+	prs, err := pullrequests.GetByIds(cfg.GitHub, ids)
+	if err != nil {
+		l.Error("there was an error while refreshing open pull requests", "error", err)
+		s.fail("open prs", err)
+		return
+	}
+
+	result, err := db.SavePullRequest(prs)
+	if err != nil {
+		l.Error("there was a problem while saving refreshed prs to db", "error", err)
+		s.PRsSkipped += result.Skipped
+		s.fail("open prs", err)
+		return
+	}
+	s.saved("open prs", result)
+
+	if err = db.SaveCompliance(compliance.Evaluate(cfg.Compliance, prs)); err != nil {
+		l.Error("there was a problem while saving refreshed prs compliance to db", "error", err)
+		s.fail("compliance", err)
+	}
+	l.Info("open pull requests refreshed", "open", len(ids), "refreshed", len(prs))
+}
+
+// checkConsistency reports the broken references in the DB, with repair the affected pull requests are fetched again;
+// the inconsistencies don't fail the run, they're in the logs and the summary.
+func checkConsistency(cfg *config.Config, l *slog.Logger, db store.IngestStore, s *summary, repair bool) {
+	c, err := db.CheckConsistency()
+	if err != nil {
+		s.fail("consistency", err)
+		return
+	}
+	s.Consistency = &c
+	if c.Total() == 0 {
+		l.Info("data is consistent")
+		return
+	}
+	l.Warn("inconsistent data found", "commits", c.OrphanCommits, "reviews", c.OrphanReviews, "reviewRequests", c.OrphanReviewRequests, "jiraRefs", c.OrphanJiraRefs, "labelEvents", c.OrphanLabelEvents, "issueLinks", c.OrphanIssueLinks, "prsWithoutRepository", c.PRsWithoutRepository)
+
+	if !repair {
+		return
+	}
+
+	ids, err := db.GetInconsistentPRIds()
+	if err != nil {
+		l.Error("can't fetch the inconsistent pull requests from DB", "error", err)
+		s.fail("consistency", err)
+		return
+	}
+
+	client.SetStage("consistency")
+	prs, err := pullrequests.GetByIds(cfg.GitHub, ids)
+	if err != nil {
+		l.Error("there was an error while fetching the inconsistent pull requests", "error", err)
+		s.fail("consistency", err)
+		return
+	}
+
+	result, err := db.SavePullRequest(prs)
+	if err != nil {
+		l.Error("there was a problem while saving repaired prs to db", "error", err)
+		s.PRsSkipped += result.Skipped
+		s.fail("consistency", err)
+		return
+	}
+	s.saved("consistency", result)
+	l.Info("inconsistent pull requests fetched again", "inconsistent", len(ids), "repaired", len(prs))
+}
+
+// syncWorkflowRuns fetches the runs of the deploy workflows of the repository, failures don't stop the sync.
+func syncWorkflowRuns(cfg *config.Config, l *slog.Logger, db store.IngestStore, s *summary, org string, repo string) {
+	since := db.GetLastWorkflowRunDate(org, repo)
+	client.SetStage("workflow runs")
+	runs, err := workflows.Get(cfg.GitHub, org, repo, since)
+	if err != nil {
+		l.Error("there was an error while fetching workflow runs", "org", org, "repo", repo, "error", err)
+		s.fail("workflow runs "+org+"/"+repo, err)
+		return
+	}
+
+	if len(runs) == 0 {
+		return
+	}
+
+	if err = db.SaveWorkflowRuns(runs); err != nil {
+		l.Error("there was a problem while saving workflow runs to db", "org", org, "repo", repo, "error", err)
+		s.fail("workflow runs "+org+"/"+repo, err)
+	}
+}
+
+// report sends the yesterday's security pull requests through the configured notifiers.
+func report(cfg *config.Config, l *slog.Logger, db store.QueryStore, s *summary) {
+	// the notifiers are only needed here, a missing token mustn't stop the sync
+	if err := cfg.ValidateNotifiers(); err != nil {
+		l.Error("invalid notifier configuration, the security digest isn't sent", "error", err)
+		s.fail("notify", err)
+		return
 	}
 
 	// TODO: It's a hacky way to inform security on slack about the last day change, in the future link this dashboard to them
-	prs, err := db.FetchSecurityPullRequests()
+	rules, err := db.ValidateSecurityRules(store.NewSecurityRules(cfg.Security))
+	if err != nil {
+		l.Error("invalid security rules, the digest is sent without them", "error", err)
+		s.fail("report", err)
+	}
+
+	prs, err := db.FetchSecurityPullRequests(rules)
 	if err != nil {
 		l.Error("can't fetch the pull requests for security", "error", err)
+		s.fail("report", err)
 	}
 
-	slack.SendMessage(prs)
+	if err := notify.SendMessage(cfg, prs); err != nil {
+		l.Error("can't send the security pull requests", "error", err)
+		s.fail("notify", err)
+	}
 }
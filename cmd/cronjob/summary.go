@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/akawula/DoraMatic/github/client"
+	"github.com/akawula/DoraMatic/store"
+)
+
+// exit codes of the cronjob, so the orchestration can tell a partial failure from a failed run
+const (
+	exitOK      = 0
+	exitFailed  = 1 // nothing was synced: invalid config, outdated schema, GitHub or the DB unreachable, no repository synced
+	exitPartial = 2 // the run finished but some repositories or stages failed
+)
+
+// summary is the machine readable outcome of a run written with --summary-json.
+type summary struct {
+	StartedAt       time.Time               `json:"started_at"`
+	DurationSeconds float64                 `json:"duration_seconds"`
+	ExitCode        int                     `json:"exit_code"`
+	ReposTotal      int                     `json:"repos_total"`
+	ReposOk         int                     `json:"repos_ok"`
+	ReposFailed     []string                `json:"repos_failed"`
+	PRsWritten      int                     `json:"prs_written"`
+	PRsInserted     int                     `json:"prs_inserted"`
+	PRsUpdated      int                     `json:"prs_updated"`
+	PRsSkipped      int                     `json:"prs_skipped"`
+	Consistency     *store.Consistency      `json:"consistency,omitempty"`
+	GitHubUsage     map[string]client.Usage `json:"github_usage"`
+	Errors          []string                `json:"errors"`
+}
+
+func newSummary() *summary {
+	return &summary{StartedAt: time.Now(), ReposFailed: []string{}, Errors: []string{}}
+}
+
+// fail records a failed stage, it turns the run into a partial failure.
+func (s *summary) fail(stage string, err error) {
+	s.Errors = append(s.Errors, fmt.Sprintf("%s: %s", stage, err))
+}
+
+func (s *summary) failRepo(org string, repo string, err error) {
+	s.ReposFailed = append(s.ReposFailed, org+"/"+repo)
+	s.fail(org+"/"+repo, err)
+}
+
+// saved counts the pull requests written by SavePullRequest, the skipped ones fail the stage.
+func (s *summary) saved(stage string, result store.SaveResult) {
+	s.PRsWritten += result.Inserted + result.Updated
+	s.PRsInserted += result.Inserted
+	s.PRsUpdated += result.Updated
+	s.PRsSkipped += result.Skipped
+	if result.Skipped > 0 {
+		s.fail(stage, result.Err())
+	}
+}
+
+// savedRepo records what SavePullRequest did with the pull requests of a repository, the repository is synced unless
+// it returned an error, which it does when none of them could be saved.
+func (s *summary) savedRepo(org string, repo string, result store.SaveResult, err error) bool {
+	if err != nil {
+		s.PRsSkipped += result.Skipped
+		s.failRepo(org, repo, err)
+		return false
+	}
+	s.ReposOk++
+	s.saved(org+"/"+repo, result)
+
+	return true
+}
+
+// exitCode is exitFailed when none of the fetched repositories could be synced and exitPartial when any stage failed,
+// the failures that stop the run early are decided by the caller.
+func (s *summary) exitCode() int {
+	switch {
+	case s.ReposTotal > 0 && s.ReposOk == 0:
+		return exitFailed
+	case len(s.Errors) > 0:
+		return exitPartial
+	}
+
+	return exitOK
+}
+
+func (s *summary) write(path string, code int) error {
+	s.ExitCode = code
+	s.DurationSeconds = time.Since(s.StartedAt).Seconds()
+	s.GitHubUsage = client.UsageByStage()
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o644)
+}
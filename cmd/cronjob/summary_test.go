@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/akawula/DoraMatic/store"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(s *summary)
+		want  int
+	}{
+		{"nothing to sync", func(s *summary) {}, exitOK},
+		{"every repository synced", func(s *summary) { s.ReposTotal, s.ReposOk = 2, 2 }, exitOK},
+		{"a stage failed", func(s *summary) {
+			s.ReposTotal, s.ReposOk = 2, 2
+			s.fail("compliance", errors.New("boom"))
+		}, exitPartial},
+		{"some repositories failed", func(s *summary) {
+			s.ReposTotal, s.ReposOk = 2, 1
+			s.failRepo("org", "repo", errors.New("boom"))
+		}, exitPartial},
+		{"every repository failed", func(s *summary) {
+			s.ReposTotal = 2
+			s.failRepo("org", "a", errors.New("boom"))
+			s.failRepo("org", "b", errors.New("boom"))
+		}, exitFailed},
+		{"every pull request of the repository skipped", func(s *summary) {
+			s.ReposTotal = 1
+			result := store.SaveResult{Skipped: 2, Errors: []store.PRError{{PrId: "a", Err: errors.New("boom")}, {PrId: "b", Err: errors.New("boom")}}}
+			s.savedRepo("org", "repo", result, result.Err())
+		}, exitFailed},
+		{"some pull requests of the repository skipped", func(s *summary) {
+			s.ReposTotal = 1
+			s.savedRepo("org", "repo", store.SaveResult{Inserted: 1, Skipped: 1, Errors: []store.PRError{{PrId: "b", Err: errors.New("boom")}}}, nil)
+		}, exitPartial},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSummary()
+			tt.setup(s)
+			if got := s.exitCode(); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/akawula/DoraMatic/github/pullrequests"
+	"github.com/akawula/DoraMatic/importer"
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/akawula/DoraMatic/store"
+)
+
+const batchSize = 500
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	jsonFile := flag.String("json", "", "JSON file with an array of pull requests, commits and reviews nested")
+	prsFile := flag.String("prs", "", "CSV file with pull requests")
+	commitsFile := flag.String("commits", "", "CSV file with commits (optional, used with -prs)")
+	reviewsFile := flag.String("reviews", "", "CSV file with reviews (optional, used with -prs)")
+	skipInvalid := flag.Bool("skip-invalid", false, "import the valid records even if some are invalid")
+	flag.Parse()
+
+	records, err := read(*jsonFile, *prsFile, *commitsFile, *reviewsFile)
+	if err != nil {
+		return err
+	}
+
+	valid := []pullrequests.PullRequest{}
+	invalid := 0
+	for i, record := range records {
+		if err := record.Validate(); err != nil {
+			invalid++
+			fmt.Fprintf(os.Stderr, "record %d (%s): %s\n", i, record.Id, err)
+			continue
+		}
+		valid = append(valid, record.ToPullRequest())
+	}
+	if invalid > 0 && !*skipInvalid {
+		return fmt.Errorf("%d of %d records are invalid, nothing was imported (use -skip-invalid to import the rest)", invalid, len(records))
+	}
+
+	cfg, err := config.Load()
+	if err = errors.Join(err, cfg.Postgres.Validate()); err != nil {
+		return err
+	}
+
+	l := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := store.NewPostgres(cfg.Postgres, l)
+	defer db.Close()
+
+	if err := db.CheckSchema(); err != nil {
+		return err
+	}
+
+	saved := store.SaveResult{}
+	for batch := range slices.Chunk(valid, batchSize) {
+		result, err := db.SavePullRequest(batch)
+		if err != nil {
+			return err
+		}
+		for _, e := range result.Errors {
+			fmt.Fprintf(os.Stderr, "pull request %s: %s\n", e.PrId, e.Err)
+		}
+		saved.Inserted += result.Inserted
+		saved.Updated += result.Updated
+		saved.Skipped += result.Skipped
+	}
+
+	if _, err := db.RecomputeDerivedColumns(); err != nil {
+		return err
+	}
+
+	l.Info("import finished", "inserted", saved.Inserted, "updated", saved.Updated, "skipped", invalid+saved.Skipped)
+	if saved.Skipped > 0 {
+		return fmt.Errorf("%d of %d pull requests couldn't be saved", saved.Skipped, len(valid))
+	}
+	return nil
+}
+
+func read(jsonFile string, prsFile string, commitsFile string, reviewsFile string) ([]importer.PullRequest, error) {
+	if len(jsonFile) > 0 {
+		f, err := os.Open(jsonFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return importer.ReadJSON(f)
+	}
+
+	if len(prsFile) == 0 {
+		return nil, errors.New("either -json or -prs is required")
+	}
+
+	files := []io.Reader{}
+	for _, name := range []string{prsFile, commitsFile, reviewsFile} {
+		if len(name) == 0 {
+			files = append(files, nil)
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+
+	return importer.ReadCSV(files[0], files[1], files[2])
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/akawula/DoraMatic/store"
+)
+
+// jirarefs extracts the JIRA references of the pull requests synced before the extraction moved to ingest, it's a one-off.
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	cfg, err := config.Load()
+	if err = errors.Join(err, cfg.Postgres.Validate()); err != nil {
+		return err
+	}
+
+	l := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := store.NewPostgres(cfg.Postgres, l)
+	defer db.Close()
+
+	if err := db.CheckSchema(); err != nil {
+		return err
+	}
+
+	refs, err := db.BackfillJiraRefs()
+	if err != nil {
+		return err
+	}
+
+	l.Info("JIRA references backfilled", "refs", refs)
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/akawula/DoraMatic/github/pullrequests"
+	"github.com/akawula/DoraMatic/github/repositories"
+	"github.com/akawula/DoraMatic/importer"
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/akawula/DoraMatic/store"
+	"github.com/shurcooL/githubv4"
+)
+
+// loadgen seeds a synthetic data set for benchmarking the metric views,
+// it replaces the teams and repositories tables, so point it at a benchmark database only.
+
+const batchSize = 500
+
+var commitTypes = []string{"feat", "fix", "chore", "refactor", "docs", "test"}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	teams := flag.Int("teams", 10, "number of teams")
+	members := flag.Int("members", 8, "average number of members per team")
+	repos := flag.Int("repos", 50, "number of repositories")
+	prs := flag.Int("prs", 10000, "number of pull requests")
+	days := flag.Int("days", 180, "spread the pull requests over the last days")
+	org := flag.String("org", "loadgen", "organization of the generated repositories")
+	seed := flag.Uint64("seed", 1, "random seed, the same seed generates the same data set")
+	flag.Parse()
+
+	if *teams < 1 || *members < 1 || *repos < 1 || *prs < 0 || *days < 1 {
+		return errors.New("teams, members, repos and days have to be positive")
+	}
+
+	cfg, err := config.Load()
+	if err = errors.Join(err, cfg.Postgres.Validate()); err != nil {
+		return err
+	}
+
+	l := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := store.NewPostgres(cfg.Postgres, l)
+	defer db.Close()
+
+	if err := db.CheckSchema(); err != nil {
+		return err
+	}
+
+	g := generator{r: rand.New(rand.NewPCG(*seed, *seed)), org: *org, now: time.Now().UTC()}
+	t := g.teams(*teams, *members)
+	if err := db.SaveTeams(t); err != nil {
+		return err
+	}
+	r := g.repositories(*repos)
+	if err := db.SaveRepos(r); err != nil {
+		return err
+	}
+
+	authors := []string{}
+	for _, m := range t {
+		authors = append(authors, m...)
+	}
+	slices.Sort(authors) // map order is random, keep the data set reproducible
+
+	start := time.Now()
+	for batch := range slices.Chunk(g.pullRequests(*prs, *days, authors, r), batchSize) {
+		result, err := db.SavePullRequest(batch)
+		if err = errors.Join(err, result.Err()); err != nil {
+			return err
+		}
+	}
+	if _, err := db.RecomputeDerivedColumns(); err != nil {
+		return err
+	}
+
+	l.Info("data set seeded", "teams", len(t), "members", len(authors), "repos", len(r), "prs", *prs, "took", time.Since(start))
+	return nil
+}
+
+type generator struct {
+	r   *rand.Rand
+	org string
+	now time.Time
+}
+
+// teams have between half and one and a half of the average members.
+func (g generator) teams(n int, members int) map[string][]string {
+	results := map[string][]string{}
+	for i := range n {
+		name := fmt.Sprintf("team-%03d", i)
+		for j := range max(members/2+g.r.IntN(members+1), 1) {
+			results[name] = append(results[name], fmt.Sprintf("user-%03d-%02d", i, j))
+		}
+	}
+
+	return results
+}
+
+func (g generator) repositories(n int) []repositories.Repository {
+	results := []repositories.Repository{}
+	for i := range n {
+		repo := repositories.Repository{Id: githubv4.String(fmt.Sprintf("R_%s_%04d", g.org, i)), Name: githubv4.String(fmt.Sprintf("repo-%04d", i))}
+		repo.Owner.Login = githubv4.String(g.org)
+		repo.PrimaryLanguage.Name = githubv4.String([]string{"Go", "TypeScript", "Python", "Java"}[i%4])
+		results = append(results, repo)
+	}
+
+	return results
+}
+
+// pullRequests are created uniformly over the period, sizes are log-normal and the waits exponential,
+// a few repositories and authors get most of the pull requests like in real organizations.
+func (g generator) pullRequests(n int, days int, authors []string, repos []repositories.Repository) []pullrequests.PullRequest {
+	results := []pullrequests.PullRequest{}
+	for i := range n {
+		repo := repos[g.skewed(len(repos))]
+		created := g.now.Add(-time.Duration(g.r.Int64N(int64(days) * int64(24*time.Hour))))
+		pr := importer.PullRequest{
+			Id:              fmt.Sprintf("PR_%s_%07d", g.org, i),
+			Url:             fmt.Sprintf("https://github.com/%s/%s/pull/%d", g.org, repo.Name, i),
+			Title:           g.title(i),
+			Author:          authors[g.skewed(len(authors))],
+			Additions:       g.logNormal(4, 1.3),
+			Deletions:       g.logNormal(3, 1.3),
+			CreatedAt:       created.Format(time.RFC3339),
+			BranchName:      fmt.Sprintf("feature/%d", i),
+			RepositoryOwner: g.org,
+			RepositoryName:  string(repo.Name),
+			State:           "OPEN",
+		}
+		if g.r.IntN(50) == 0 {
+			pr.BranchName = fmt.Sprintf("hotfix/%d", i)
+		}
+
+		at := created.Add(-g.exp(24 * time.Hour)) // the first commit is usually older than the pull request
+		for j := range 1 + int(g.r.ExpFloat64()*3) {
+			pr.Commits = append(pr.Commits, importer.Commit{
+				Id:          fmt.Sprintf("C_%s_%07d_%02d", g.org, i, j),
+				Message:     g.commitMessage(),
+				CommittedAt: at.Format(time.RFC3339),
+				Additions:   g.logNormal(3, 1.2),
+				Deletions:   g.logNormal(2, 1.2),
+			})
+			at = at.Add(g.exp(4 * time.Hour))
+		}
+
+		review := created.Add(g.exp(6 * time.Hour))
+		pr.ReviewRequestedAt = created.Add(g.exp(30 * time.Minute)).Format(time.RFC3339)
+		for j := range g.r.IntN(4) {
+			state := "COMMENTED"
+			if j == 0 || g.r.IntN(3) == 0 {
+				state = "APPROVED"
+			}
+			pr.Reviews = append(pr.Reviews, importer.Review{
+				Id:          fmt.Sprintf("PRR_%s_%07d_%d", g.org, i, j),
+				Author:      authors[g.r.IntN(len(authors))],
+				State:       state,
+				SubmittedAt: review.Format(time.RFC3339),
+			})
+			review = review.Add(g.exp(3 * time.Hour))
+		}
+
+		merged := review.Add(g.exp(18 * time.Hour))
+		switch p := g.r.IntN(100); {
+		case p < 85 && merged.Before(g.now):
+			pr.State = "MERGED"
+			pr.MergedAt = merged.Format(time.RFC3339)
+			pr.MergeCommitSha = fmt.Sprintf("%040x", g.r.Uint64())
+		case p < 90:
+			pr.State = "CLOSED"
+		}
+
+		results = append(results, pr.ToPullRequest())
+	}
+
+	return results
+}
+
+// skewed picks an index in [0, n) with the lower ones much more likely.
+func (g generator) skewed(n int) int {
+	return min(int(g.r.ExpFloat64()*float64(n)/4), n-1)
+}
+
+func (g generator) exp(mean time.Duration) time.Duration {
+	return time.Duration(g.r.ExpFloat64() * float64(mean))
+}
+
+func (g generator) logNormal(mu float64, sigma float64) int {
+	return int(math.Exp(mu + sigma*g.r.NormFloat64()))
+}
+
+func (g generator) title(i int) string {
+	switch g.r.IntN(40) {
+	case 0:
+		return fmt.Sprintf("Revert \"Change %d\"", i)
+	case 1, 2, 3:
+		return fmt.Sprintf("Change %d", i)
+	default:
+		return fmt.Sprintf("LOAD-%d Change %d", g.r.IntN(5000), i)
+	}
+}
+
+// commitMessage is conventional most of the time.
+func (g generator) commitMessage() string {
+	if g.r.IntN(4) == 0 {
+		return "update"
+	}
+
+	return fmt.Sprintf("%s: change", commitTypes[g.r.IntN(len(commitTypes))])
+}
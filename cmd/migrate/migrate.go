@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/akawula/DoraMatic/store"
+)
+
+const usage = `usage: migrate <command>
+  status    show the applied version and pending migrations
+  up        apply all pending migrations
+  down N    revert the last N migrations
+  force V   set the version to V without running migrations (clears dirty state)`
+
+func logger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, nil))
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	cfg, err := config.Load()
+	if err = errors.Join(err, cfg.Postgres.Validate()); err != nil {
+		return err
+	}
+
+	m, err := store.NewMigrator(cfg.Postgres, logger())
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "status":
+		return status(m)
+	case "up":
+		return m.Up()
+	case "down":
+		n, err := argument(args)
+		if err != nil {
+			return err
+		}
+		return m.Down(n)
+	case "force":
+		v, err := argument(args)
+		if err != nil {
+			return err
+		}
+		return m.Force(v)
+	default:
+		return errors.New(usage)
+	}
+}
+
+func argument(args []string) (int, error) {
+	if len(args) != 2 {
+		return 0, errors.New(usage)
+	}
+
+	return strconv.Atoi(args[1])
+}
+
+func status(m *store.Migrator) error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	migrations, err := store.Migrations()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version: %d, dirty: %t\n", version, dirty)
+	for _, migration := range migrations {
+		state := "pending"
+		if migration.Version <= version {
+			state = "applied"
+		}
+		fmt.Printf("%04d %-30s %s\n", migration.Version, migration.Name, state)
+	}
+
+	return nil
+}
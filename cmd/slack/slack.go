@@ -1,11 +1,22 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/akawula/DoraMatic/internal/config"
 	"github.com/akawula/DoraMatic/slack"
 	"github.com/akawula/DoraMatic/store"
 )
 
 func main() {
+	cfg, err := config.Load()
+	if err = errors.Join(err, cfg.Slack.Validate()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	prs := []store.SecurityPR{}
-	slack.SendMessage(prs)
+	slack.New(cfg.Slack).SendMessage(prs)
 }
@@ -0,0 +1,76 @@
+package compliance
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/akawula/DoraMatic/github/pullrequests"
+	"github.com/akawula/DoraMatic/internal/config"
+)
+
+var (
+	checkboxRegex = regexp.MustCompile(`(?m)^\s*[-*] \[[ xX]\] `)
+	headingRegex  = regexp.MustCompile(`(?m)^#{1,6}\s+(.*)$`)
+	commentRegex  = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+type Result struct {
+	PrId   string
+	Score  float64  // share of the enabled rules the pull request passed, 1 is fully compliant
+	Failed []string // names of the failed rules
+}
+
+// Evaluate checks the pull requests against the PR template rules enabled in the config.
+func Evaluate(cfg config.Compliance, prs []pullrequests.PullRequest) []Result {
+	results := []Result{}
+	if len(cfg.Rules) == 0 {
+		return results
+	}
+
+	ticket := regexp.MustCompile(cfg.TicketRegex)
+	for _, pr := range prs {
+		body := commentRegex.ReplaceAllString(string(pr.Body), "")
+		failed := []string{}
+		for _, rule := range cfg.Rules {
+			var ok bool
+			switch rule {
+			case "checklist":
+				ok = checkboxRegex.MatchString(body)
+			case "risk":
+				ok = sectionFilled(body, cfg.RiskHeading)
+			case "ticket":
+				ok = ticket.MatchString(string(pr.Title)) || ticket.MatchString(string(pr.HeadRefName)) || ticket.MatchString(body)
+			}
+			if !ok {
+				failed = append(failed, rule)
+			}
+		}
+
+		results = append(results, Result{
+			PrId:   string(pr.Id),
+			Score:  float64(len(cfg.Rules)-len(failed)) / float64(len(cfg.Rules)),
+			Failed: failed,
+		})
+	}
+
+	return results
+}
+
+// sectionFilled reports whether the markdown heading containing name has any content before the next heading.
+func sectionFilled(body string, name string) bool {
+	headings := headingRegex.FindAllStringSubmatchIndex(body, -1)
+	for i, h := range headings {
+		if !strings.Contains(strings.ToLower(body[h[2]:h[3]]), strings.ToLower(name)) {
+			continue
+		}
+
+		end := len(body)
+		if i+1 < len(headings) {
+			end = headings[i+1][0]
+		}
+
+		return len(strings.TrimSpace(body[h[1]:end])) > 0
+	}
+
+	return false
+}
@@ -0,0 +1,64 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/smtp"
+	"strings"
+
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/akawula/DoraMatic/store"
+)
+
+const subject = "Looks like there were new Pull Requests yesterday"
+
+var securityTemplate = template.Must(template.New("security").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+<p>{{ .Subject }}</p>
+<hr>
+{{ range .PullRequests }}
+<p>
+  {{ .Title }}<br>
+  <b>{{ .RepositoryName }}</b> [+{{ .Additions }} -{{ .Deletions }}] Author: {{ .Author }}<br>
+  {{ if eq .State "MERGED" }}State: {{ .State }}, Merged At: {{ .MergedAt.String }}{{ else }}State: {{ .State }}, Created At: {{ .CreatedAt }}{{ end }}<br>
+  <a href="{{ .Url }}">Open</a>
+</p>
+<hr>
+{{ end }}
+</body>
+</html>
+`))
+
+type Email struct {
+	cfg config.SMTP
+}
+
+func New(cfg config.SMTP) *Email {
+	return &Email{cfg: cfg}
+}
+
+func (e *Email) SendMessage(prs []store.SecurityPR) error {
+	body := bytes.Buffer{}
+	if err := securityTemplate.Execute(&body, map[string]interface{}{"Subject": subject, "PullRequests": prs}); err != nil {
+		return err
+	}
+
+	return e.sendEmail(subject, body.String())
+}
+
+func (e *Email) sendEmail(subject string, html string) error {
+	if err := e.cfg.Validate(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if len(e.cfg.Username) > 0 {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=\"UTF-8\"\r\n\r\n%s", e.cfg.From, strings.Join(e.cfg.To, ", "), subject, html)
+
+	return smtp.SendMail(e.cfg.Host+":"+e.cfg.Port, auth, e.cfg.From, e.cfg.To, []byte(msg))
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/akawula/DoraMatic/internal/config"
+)
+
+// types of the published events, the subject of an event is <EVENTS_SUBJECT_PREFIX>.<type>
+const (
+	PRUpserted         = "pr.upserted"
+	ReviewUpserted     = "review.upserted"
+	DeploymentRecorded = "deployment.recorded"
+	SyncCompleted      = "sync.completed"
+)
+
+// Event is published as JSON, Data holds the fields of the entity downstream systems need to react.
+type Event struct {
+	Type string      `json:"type"`
+	Id   string      `json:"id,omitempty"`
+	At   time.Time   `json:"at"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Publisher sends the events of the ingested entities to a message broker.
+type Publisher interface {
+	Publish(events []Event) error
+	Close() error
+}
+
+// Noop is the publisher when no broker is configured, it drops the events.
+type Noop struct{}
+
+func (Noop) Publish([]Event) error { return nil }
+
+func (Noop) Close() error { return nil }
+
+// New connects to the broker configured in EVENTS_BROKER, without one the events are dropped.
+func New(cfg config.Events) (Publisher, error) {
+	switch cfg.Broker {
+	case "":
+		return Noop{}, nil
+	case "nats":
+		return dialNATS(cfg)
+	default:
+		return nil, fmt.Errorf("unknown events broker %q", cfg.Broker)
+	}
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akawula/DoraMatic/internal/config"
+)
+
+const natsTimeout = 10 * time.Second
+
+// nats publishes with the NATS client protocol, it's plain text over TCP, so no client library is needed.
+type nats struct {
+	conn   net.Conn
+	w      *bufio.Writer
+	prefix string
+	mu     sync.Mutex
+	err    error // -ERR sent by the server, the connection is closed after it
+}
+
+func dialNATS(cfg config.Events) (*nats, error) {
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(cfg.URL, "nats://"), natsTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to nats: %w", err)
+	}
+
+	n := &nats{conn: conn, w: bufio.NewWriter(conn), prefix: cfg.SubjectPrefix}
+	r := bufio.NewReader(conn)
+	if err := n.handshake(r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go n.read(r)
+
+	return n, nil
+}
+
+// handshake reads the INFO of the server and waits for the PONG of a PING, the server answers -ERR instead
+// when it refuses the connection.
+func (n *nats) handshake(r *bufio.Reader) error {
+	n.conn.SetDeadline(time.Now().Add(natsTimeout))
+	defer n.conn.SetDeadline(time.Time{})
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("can't read the nats INFO: %w", err)
+	}
+	info, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		return fmt.Errorf("unexpected nats greeting %q", strings.TrimSpace(line))
+	}
+	var server struct {
+		TLSRequired  bool `json:"tls_required"`
+		AuthRequired bool `json:"auth_required"`
+	}
+	if err := json.Unmarshal([]byte(info), &server); err != nil {
+		return fmt.Errorf("can't parse the nats INFO: %w", err)
+	}
+	if server.TLSRequired || server.AuthRequired {
+		return errors.New("nats requires TLS or authentication, they aren't supported")
+	}
+
+	fmt.Fprint(n.w, "CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"doramatic\"}\r\nPING\r\n")
+	if err := n.w.Flush(); err != nil {
+		return fmt.Errorf("can't send the nats CONNECT: %w", err)
+	}
+	if line, err = r.ReadString('\n'); err != nil {
+		return fmt.Errorf("can't read the nats PONG: %w", err)
+	}
+	if strings.TrimSpace(line) != "PONG" {
+		return fmt.Errorf("nats refused the connection: %s", strings.TrimSpace(line))
+	}
+
+	return nil
+}
+
+// read answers the PINGs of the server, it would close the connection otherwise, and keeps its errors.
+func (n *nats) read(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		n.mu.Lock()
+		switch {
+		case line == "PING":
+			n.conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+			fmt.Fprint(n.w, "PONG\r\n")
+			n.w.Flush()
+		case strings.HasPrefix(line, "-ERR"):
+			n.err = fmt.Errorf("nats: %s", line)
+		}
+		n.mu.Unlock()
+	}
+}
+
+func (n *nats) Publish(events []Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("can't marshal the %s event: %w", event.Type, err)
+		}
+		// the buffer is flushed when it's full too, a broker that stops reading mustn't block the sync
+		n.conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+		fmt.Fprintf(n.w, "PUB %s.%s %d\r\n%s\r\n", n.prefix, event.Type, len(payload), payload)
+	}
+	n.conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+
+	return n.w.Flush()
+}
+
+func (n *nats) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+	n.w.Flush()
+
+	return n.conn.Close()
+}
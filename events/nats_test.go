@@ -0,0 +1,225 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akawula/DoraMatic/internal/config"
+)
+
+// fakeNATS accepts one connection and greets it with info, serve talks to the client from then on.
+func fakeNATS(t *testing.T, info string, serve func(conn net.Conn, r *bufio.Reader)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "INFO %s\r\n", info)
+		serve(conn, bufio.NewReader(conn))
+	}()
+
+	return ln.Addr().String()
+}
+
+// handshake answers the CONNECT and PING of the client like a NATS server, it returns the CONNECT line.
+func handshake(conn net.Conn, r *bufio.Reader) (string, error) {
+	connect, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if ping, err := r.ReadString('\n'); err != nil || ping != "PING\r\n" {
+		return "", fmt.Errorf("expected PING, got %q: %v", ping, err)
+	}
+	_, err = fmt.Fprint(conn, "PONG\r\n")
+
+	return connect, err
+}
+
+func TestDialNATSHandshake(t *testing.T) {
+	connects := make(chan string, 1)
+	addr := fakeNATS(t, `{"server_id":"test"}`, func(conn net.Conn, r *bufio.Reader) {
+		connect, err := handshake(conn, r)
+		if err != nil {
+			t.Error(err)
+		}
+		connects <- connect
+		io.Copy(io.Discard, r)
+	})
+
+	n, err := dialNATS(config.Events{URL: "nats://" + addr, SubjectPrefix: "doramatic"})
+	if err != nil {
+		t.Fatalf("dialNATS() error = %v", err)
+	}
+	defer n.Close()
+
+	connect := <-connects
+	if !strings.HasPrefix(connect, "CONNECT {") || !strings.HasSuffix(connect, "}\r\n") {
+		t.Errorf("CONNECT = %q", connect)
+	}
+	var options map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(connect), "CONNECT ")), &options); err != nil {
+		t.Errorf("CONNECT options aren't JSON: %v", err)
+	}
+}
+
+func TestDialNATSRefused(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  string
+		serve func(conn net.Conn, r *bufio.Reader)
+		want  string
+	}{
+		{"tls required", `{"tls_required":true}`, func(conn net.Conn, r *bufio.Reader) { io.Copy(io.Discard, r) }, "TLS"},
+		{"auth required", `{"auth_required":true}`, func(conn net.Conn, r *bufio.Reader) { io.Copy(io.Discard, r) }, "authentication"},
+		{"connect rejected", `{}`, func(conn net.Conn, r *bufio.Reader) {
+			r.ReadString('\n')
+			r.ReadString('\n')
+			fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+		}, "Authorization Violation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := fakeNATS(t, tt.info, tt.serve)
+			_, err := dialNATS(config.Events{URL: addr})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("dialNATS() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNATSPublish(t *testing.T) {
+	type pub struct {
+		subject string
+		size    int
+		payload string
+	}
+	pubs := make(chan pub, 2)
+	addr := fakeNATS(t, `{}`, func(conn net.Conn, r *bufio.Reader) {
+		if _, err := handshake(conn, r); err != nil {
+			t.Error(err)
+			return
+		}
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) != 3 || fields[0] != "PUB" {
+				t.Errorf("unexpected line %q", line)
+				return
+			}
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				t.Error(err)
+				return
+			}
+			pubs <- pub{subject: fields[1], size: size, payload: string(payload)}
+		}
+	})
+
+	n, err := dialNATS(config.Events{URL: addr, SubjectPrefix: "doramatic"})
+	if err != nil {
+		t.Fatalf("dialNATS() error = %v", err)
+	}
+	defer n.Close()
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	err = n.Publish([]Event{
+		{Type: PRUpserted, Id: "PR_1", At: at, Data: map[string]string{"title": "żółw"}},
+		{Type: SyncCompleted, At: at},
+	})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	tests := []struct {
+		subject string
+		payload string
+	}{
+		{"doramatic.pr.upserted", `{"type":"pr.upserted","id":"PR_1","at":"2024-01-02T03:04:05Z","data":{"title":"żółw"}}`},
+		{"doramatic.sync.completed", `{"type":"sync.completed","at":"2024-01-02T03:04:05Z"}`},
+	}
+	for _, tt := range tests {
+		got := <-pubs
+		if got.subject != tt.subject {
+			t.Errorf("subject = %q, want %q", got.subject, tt.subject)
+		}
+		// the size is in bytes, the multi-byte characters of the title count more than once
+		if got.size != len(tt.payload) || got.payload != tt.payload+"\r\n" {
+			t.Errorf("PUB size %d payload %q, want %d %q", got.size, got.payload, len(tt.payload), tt.payload+"\r\n")
+		}
+	}
+}
+
+func TestNATSPublishAsyncError(t *testing.T) {
+	addr := fakeNATS(t, `{}`, func(conn net.Conn, r *bufio.Reader) {
+		if _, err := handshake(conn, r); err != nil {
+			t.Error(err)
+			return
+		}
+		fmt.Fprint(conn, "-ERR 'Maximum Payload Violation'\r\n")
+		io.Copy(io.Discard, r)
+	})
+
+	n, err := dialNATS(config.Events{URL: addr, SubjectPrefix: "doramatic"})
+	if err != nil {
+		t.Fatalf("dialNATS() error = %v", err)
+	}
+	defer n.Close()
+
+	// the error is read in the background, it's returned by the publishes after it arrived
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err = n.Publish([]Event{{Type: SyncCompleted}})
+		if err != nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err == nil || !strings.Contains(err.Error(), "Maximum Payload Violation") {
+		t.Errorf("Publish() error = %v, want the -ERR of the server", err)
+	}
+}
+
+func TestNATSAnswersPing(t *testing.T) {
+	pongs := make(chan string, 1)
+	addr := fakeNATS(t, `{}`, func(conn net.Conn, r *bufio.Reader) {
+		if _, err := handshake(conn, r); err != nil {
+			t.Error(err)
+			return
+		}
+		fmt.Fprint(conn, "PING\r\n")
+		line, _ := r.ReadString('\n')
+		pongs <- line
+		io.Copy(io.Discard, r)
+	})
+
+	n, err := dialNATS(config.Events{URL: addr})
+	if err != nil {
+		t.Fatalf("dialNATS() error = %v", err)
+	}
+	defer n.Close()
+
+	if pong := <-pongs; pong != "PONG\r\n" {
+		t.Errorf("answer to PING = %q, want PONG", pong)
+	}
+}
@@ -2,16 +2,22 @@ package client
 
 import (
 	"context"
-	"os"
+	"net/http"
 
+	"github.com/akawula/DoraMatic/internal/config"
 	"github.com/shurcooL/githubv4"
 	"golang.org/x/oauth2"
 )
 
-func Get() *githubv4.Client {
+func Get(cfg config.GitHub) *githubv4.Client {
+	return githubv4.NewClient(httpClient(cfg))
+}
+
+// httpClient authenticates the requests with the token and reports GitHub's rate limits as a RateLimitError.
+func httpClient(cfg config.GitHub) *http.Client {
 	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_TOKEN")},
+		&oauth2.Token{AccessToken: cfg.Token},
 	)
-	httpClient := oauth2.NewClient(context.Background(), src)
-	return githubv4.NewClient(httpClient)
+	base := &http.Client{Transport: &rateLimitTransport{base: http.DefaultTransport}}
+	return oauth2.NewClient(context.WithValue(context.Background(), oauth2.HTTPClient, base), src)
 }
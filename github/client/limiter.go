@@ -0,0 +1,64 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket shared by every GitHub client of the process, so concurrent fetchers together stay
+// under GitHub's secondary rate limits; once any request gets rate limited, all of them pause for the asked time.
+type limiter struct {
+	mu          sync.Mutex
+	rate        float64 // requests per second
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+var githubLimiter = newLimiter(10, 20)
+
+func newLimiter(rate float64, burst float64) *limiter {
+	return &limiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until the request can be sent.
+func (l *limiter) wait() {
+	for {
+		d := l.reserve()
+		if d == 0 {
+			return
+		}
+		time.Sleep(d)
+	}
+}
+
+// reserve takes a token and returns 0, or returns how long to wait before trying again.
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// pause holds every request of the process for d.
+func (l *limiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
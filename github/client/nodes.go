@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// maxNodeIds is the most ids GitHub accepts in a single nodes query.
+const maxNodeIds = 100
+
+// Nodes fetches the objects by their node ids with as many nodes(ids: $ids) queries as the chunk size requires.
+// T selects the fields through an inline fragment, e.g. struct{ PullRequest PullRequest `graphql:"... on PullRequest"` },
+// the other query variables (besides ids) are passed in variables. Ids that don't exist anymore come back as zero values.
+func Nodes[T any](c *githubv4.Client, ids []string, chunk int, variables map[string]interface{}) ([]T, error) {
+	var q struct {
+		Nodes []T `graphql:"nodes(ids: $ids)"`
+	}
+
+	results := []T{}
+	for ids := range slices.Chunk(ids, min(max(chunk, 1), maxNodeIds)) {
+		nodeIds := []githubv4.ID{}
+		for _, id := range ids {
+			nodeIds = append(nodeIds, githubv4.ID(id))
+		}
+		v := maps.Clone(variables)
+		if v == nil {
+			v = map[string]interface{}{}
+		}
+		v["ids"] = nodeIds
+
+		err := Retry(DefaultBackoff, func() error {
+			return c.Query(context.Background(), &q, v)
+		})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, q.Nodes...)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/akawula/DoraMatic/internal/config"
+)
+
+const restURL = "https://api.github.com"
+
+// REST is a minimal client for the endpoints GraphQL doesn't cover.
+type REST struct {
+	httpClient *http.Client
+}
+
+func GetREST(cfg config.GitHub) *REST {
+	return &REST{httpClient: httpClient(cfg)}
+}
+
+// Get fetches the path (e.g. /repos/org/repo/actions/workflows) and decodes the JSON response into v,
+// failed requests are retried with the DefaultBackoff.
+func (r *REST) Get(path string, v interface{}) error {
+	return Retry(DefaultBackoff, func() error { return r.get(path, v) })
+}
+
+func (r *REST) get(path string, v interface{}) error {
+	req, err := http.NewRequest("GET", restURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return Permanent(fmt.Errorf("GitHub API returned %d for %s", resp.StatusCode, path))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub API returned %d for %s", resp.StatusCode, path)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
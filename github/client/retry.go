@@ -0,0 +1,120 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Backoff is the retry policy of a single GitHub call.
+type Backoff struct {
+	Initial    time.Duration // wait before the first retry, doubled on every next one
+	Max        time.Duration // cap of a single wait
+	MaxElapsed time.Duration // give up once the call has been retried for that long
+}
+
+var DefaultBackoff = Backoff{Initial: time.Second, Max: time.Minute, MaxElapsed: 5 * time.Minute}
+
+// the clock of the retries, replaced by the tests so they don't wait
+var (
+	now   = time.Now
+	sleep = time.Sleep
+)
+
+// RateLimitError is returned when GitHub rate limited the request (primary or secondary limit) and said how long to wait.
+type RateLimitError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("GitHub API rate limited the request (%d), retry after %s", e.StatusCode, e.RetryAfter)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks the error as not worth retrying, Retry returns it straight away.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Retry calls fn until it succeeds, returns a permanent error or the policy's MaxElapsed is over.
+// Rate limited calls wait for as long as GitHub asked, the others back off exponentially with full jitter.
+func Retry(b Backoff, fn func() error) error {
+	start := now()
+	wait := b.Initial
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+
+		pause := rand.N(wait) + 1
+		var limited *RateLimitError
+		if errors.As(err, &limited) {
+			pause = limited.RetryAfter
+		}
+		if now().Sub(start)+pause > b.MaxElapsed {
+			return err
+		}
+
+		sleep(pause)
+		wait = min(wait*2, b.Max)
+	}
+}
+
+// rateLimitTransport paces the requests with the process-wide limiter and turns GitHub's rate limit responses
+// into a RateLimitError so the callers can wait for it.
+type rateLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	githubLimiter.wait()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	usage.record(req, resp)
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return resp, nil
+	}
+
+	retryAfter, ok := parseRetryAfter(resp.Header)
+	if !ok {
+		return resp, nil // a plain permission error
+	}
+	resp.Body.Close()
+	githubLimiter.pause(retryAfter)
+
+	return nil, &RateLimitError{StatusCode: resp.StatusCode, RetryAfter: retryAfter}
+}
+
+// parseRetryAfter reads the wait from Retry-After (secondary limits) or X-RateLimit-Reset once the primary limit is used up.
+func parseRetryAfter(h http.Header) (time.Duration, bool) {
+	if s, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+		return time.Duration(max(s, 1)) * time.Second, true
+	}
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Minute, true
+	}
+
+	return max(time.Unix(reset, 0).Sub(now()), time.Second), true
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeClock makes the retries sleep on a fake clock, it returns the sleeps Retry asked for.
+func fakeClock(t *testing.T) *[]time.Duration {
+	t.Helper()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sleeps := []time.Duration{}
+	now = func() time.Time { return clock }
+	sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		clock = clock.Add(d)
+	}
+	t.Cleanup(func() { now, sleep = time.Now, time.Sleep })
+
+	return &sleeps
+}
+
+func TestRetry(t *testing.T) {
+	boom := errors.New("boom")
+	limited := &RateLimitError{StatusCode: http.StatusForbidden, RetryAfter: 30 * time.Second}
+	tooLong := &RateLimitError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}
+	backoff := Backoff{Initial: time.Second, Max: 4 * time.Second, MaxElapsed: time.Minute}
+
+	tests := []struct {
+		name      string
+		errs      []error // returned by the calls in order, nil once they run out
+		wantErr   error
+		wantCalls int
+		check     func(t *testing.T, sleeps []time.Duration)
+	}{
+		{name: "succeeds at once", wantCalls: 1},
+		{name: "succeeds after retries", errs: []error{boom, boom}, wantCalls: 3, check: func(t *testing.T, sleeps []time.Duration) {
+			// full jitter: each wait is within the doubled, capped backoff
+			for i, limit := range []time.Duration{time.Second, 2 * time.Second} {
+				if sleeps[i] <= 0 || sleeps[i] > limit {
+					t.Errorf("sleep %d = %s, want within (0, %s]", i, sleeps[i], limit)
+				}
+			}
+		}},
+		{name: "permanent error isn't retried", errs: []error{Permanent(boom)}, wantErr: boom, wantCalls: 1},
+		{name: "permanent error after retries", errs: []error{boom, Permanent(boom)}, wantErr: boom, wantCalls: 2},
+		{name: "rate limit waits as asked", errs: []error{limited}, wantCalls: 2, check: func(t *testing.T, sleeps []time.Duration) {
+			if len(sleeps) != 1 || sleeps[0] != 30*time.Second {
+				t.Errorf("sleeps = %v, want [30s]", sleeps)
+			}
+		}},
+		// 30s + 30s fit in the minute, the third wait doesn't
+		{name: "gives up once MaxElapsed is over", errs: []error{limited, limited, limited, limited}, wantErr: limited, wantCalls: 3},
+		{name: "gives up when the rate limit wait is longer than MaxElapsed", errs: []error{tooLong}, wantErr: tooLong, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sleeps := fakeClock(t)
+			calls := 0
+			err := Retry(backoff, func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Retry() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.check != nil {
+				tt.check(t, *sleeps)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	fakeClock(t)
+	reset := now().Add(90 * time.Second).Unix()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+		wantOk  bool
+	}{
+		{"secondary limit", map[string]string{"Retry-After": "20"}, 20 * time.Second, true},
+		{"retry after zero waits a second", map[string]string{"Retry-After": "0"}, time.Second, true},
+		{"retry after wins over the reset", map[string]string{"Retry-After": "5", "X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1"}, 5 * time.Second, true},
+		{"primary limit used up", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(reset, 10)}, 90 * time.Second, true},
+		{"reset in the past waits a second", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(reset-3600, 10)}, time.Second, true},
+		{"unreadable reset waits a minute", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "soon"}, time.Minute, true},
+		{"requests left is a permission error", map[string]string{"X-RateLimit-Remaining": "10", "X-RateLimit-Reset": strconv.FormatInt(reset, 10)}, 0, false},
+		{"no headers is a permission error", map[string]string{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			got, ok := parseRetryAfter(h)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseRetryAfter() = %s, %t, want %s, %t", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Usage is the GitHub API budget consumed by a stage of the run.
+type Usage struct {
+	GraphQLCalls  int `json:"graphql_calls"`
+	GraphQLPoints int `json:"graphql_points"`
+	RESTCalls     int `json:"rest_calls"`
+}
+
+// usageTracker attributes the requests to the current stage, the GraphQL points come from the growth
+// of X-RateLimit-Used between the responses, as the queries don't ask for their cost.
+type usageTracker struct {
+	mu       sync.Mutex
+	stage    string
+	stages   map[string]*Usage
+	lastUsed int
+}
+
+var usage = &usageTracker{stage: "other", stages: map[string]*Usage{}, lastUsed: -1}
+
+// SetStage attributes the following GitHub requests to the stage.
+func SetStage(stage string) {
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+
+	usage.stage = stage
+}
+
+// UsageByStage returns the budget consumed so far per stage.
+func UsageByStage() map[string]Usage {
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+
+	results := map[string]Usage{}
+	for stage, u := range usage.stages {
+		results[stage] = *u
+	}
+
+	return results
+}
+
+func (t *usageTracker) record(req *http.Request, resp *http.Response) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u, ok := t.stages[t.stage]
+	if !ok {
+		u = &Usage{}
+		t.stages[t.stage] = u
+	}
+
+	if req.URL.Path != "/graphql" {
+		u.RESTCalls++
+		return
+	}
+
+	u.GraphQLCalls++
+	used, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Used"))
+	if err != nil {
+		return
+	}
+	switch {
+	case t.lastUsed < 0: // the first query of the process, the window may have been used by others before
+		u.GraphQLPoints++
+	case used >= t.lastUsed:
+		u.GraphQLPoints += used - t.lastUsed
+	default: // the rate limit window was reset in between
+		u.GraphQLPoints += used
+	}
+	t.lastUsed = used
+}
@@ -0,0 +1,91 @@
+package organizations
+
+import (
+	"context"
+
+	"github.com/akawula/DoraMatic/github/client"
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/shurcooL/githubv4"
+)
+
+// Identity maps a GitHub login to the corporate identity of the organization's SAML provider.
+type Identity struct {
+	Org    string
+	Login  string
+	NameId string
+	Email  string
+}
+
+// GetIdentities returns the SAML external identities of the given organizations that have SAML single sign-on enabled,
+// the token needs the admin:org scope.
+func GetIdentities(cfg config.GitHub, orgs []string) ([]Identity, error) {
+	results := []Identity{}
+	for _, org := range orgs {
+		identities, err := getIdentities(cfg, org)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, identities...)
+	}
+
+	return results, nil
+}
+
+func getIdentities(cfg config.GitHub, org string) ([]Identity, error) {
+	var q struct {
+		Organization struct {
+			SamlIdentityProvider *struct {
+				ExternalIdentities struct {
+					Nodes []struct {
+						SamlIdentity struct {
+							NameId githubv4.String
+							Emails []struct {
+								Value githubv4.String
+							}
+						}
+						User struct {
+							Login githubv4.String
+						}
+					}
+					PageInfo struct {
+						HasNextPage githubv4.Boolean
+						EndCursor   githubv4.String
+					}
+				} `graphql:"externalIdentities(first: 100, after: $after)"`
+			}
+		} `graphql:"organization(login: $organization)"`
+	}
+
+	client := client.Get(cfg)
+	variables := map[string]interface{}{"organization": githubv4.String(org), "after": (*githubv4.String)(nil)}
+	results := []Identity{}
+	for {
+		err := client.Query(context.Background(), &q, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		provider := q.Organization.SamlIdentityProvider
+		if provider == nil { // SAML isn't enabled for the organization
+			break
+		}
+
+		for _, node := range provider.ExternalIdentities.Nodes {
+			if len(node.User.Login) == 0 { // identity not linked to a GitHub account
+				continue
+			}
+			email := ""
+			if len(node.SamlIdentity.Emails) > 0 {
+				email = string(node.SamlIdentity.Emails[0].Value)
+			}
+			results = append(results, Identity{Org: org, Login: string(node.User.Login), NameId: string(node.SamlIdentity.NameId), Email: email})
+		}
+
+		if !provider.ExternalIdentities.PageInfo.HasNextPage {
+			break
+		}
+		variables["after"] = githubv4.String(provider.ExternalIdentities.PageInfo.EndCursor)
+	}
+
+	return results, nil
+}
@@ -2,10 +2,12 @@ package organizations
 
 import (
 	"context"
-	"os"
+	"slices"
+	"strings"
 
+	"github.com/akawula/DoraMatic/github/client"
+	"github.com/akawula/DoraMatic/internal/config"
 	"github.com/shurcooL/githubv4"
-	"golang.org/x/oauth2"
 )
 
 var q struct {
@@ -22,12 +24,8 @@ var q struct {
 	}
 }
 
-func Get() ([]string, error) {
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_TOKEN")},
-	)
-	httpClient := oauth2.NewClient(context.Background(), src)
-	client := githubv4.NewClient(httpClient)
+func Get(cfg config.GitHub) ([]string, error) {
+	client := client.Get(cfg)
 
 	err := client.Query(context.Background(), &q, nil)
 	if err != nil {
@@ -36,8 +34,21 @@ func Get() ([]string, error) {
 
 	results := []string{}
 	for _, login := range q.Viewer.Organizations.Nodes {
+		if !allowed(string(login.Login), cfg.OrgsInclude, cfg.OrgsExclude) {
+			continue
+		}
 		results = append(results, string(login.Login))
 	}
 
 	return results, nil
 }
+
+// allowed reports whether the org should be synced, an empty include list means every org is included.
+func allowed(org string, include []string, exclude []string) bool {
+	org = strings.ToLower(org)
+	if slices.Contains(exclude, org) {
+		return false
+	}
+
+	return len(include) == 0 || slices.Contains(include, org)
+}
@@ -3,10 +3,10 @@ package organizations
 import (
 	"context"
 	"maps"
-	"os"
 
+	"github.com/akawula/DoraMatic/github/client"
+	"github.com/akawula/DoraMatic/internal/config"
 	"github.com/shurcooL/githubv4"
-	"golang.org/x/oauth2"
 )
 
 var query struct {
@@ -34,15 +34,11 @@ var query struct {
 	}
 }
 
-func GetTeams() (map[string][]string, error) {
-	orgs, err := Get()
-	if err != nil {
-		return nil, err
-	}
-
+// GetTeams returns the members of every team of the given organizations.
+func GetTeams(cfg config.GitHub, orgs []string) (map[string][]string, error) {
 	results := map[string][]string{}
 	for _, org := range orgs {
-		team, err := getTeam(org)
+		team, err := getTeam(cfg, org)
 		if err != nil {
 			return nil, err
 		}
@@ -52,23 +48,16 @@ func GetTeams() (map[string][]string, error) {
 	return results, nil
 }
 
-func getTeam(org string) (map[string][]string, error) {
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_TOKEN")},
-	)
-	httpClient := oauth2.NewClient(context.Background(), src)
-	client := githubv4.NewClient(httpClient)
+func getTeam(cfg config.GitHub, org string) (map[string][]string, error) {
+	c := client.Get(cfg)
 	variables := map[string]interface{}{"organization": githubv4.String(org), "teamsAfter": (*githubv4.String)(nil), "membersAfter": (*githubv4.String)(nil)}
-	retries := 3
 	results := make(map[string][]string)
 
 	for {
-		err := client.Query(context.Background(), &query, variables)
+		err := client.Retry(client.DefaultBackoff, func() error {
+			return c.Query(context.Background(), &query, variables)
+		})
 		if err != nil {
-			if retries > 0 {
-				retries--
-				continue
-			}
 			return nil, err
 		}
 
@@ -3,50 +3,139 @@ package pullrequests
 import (
 	"context"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/akawula/DoraMatic/github/client"
 	"github.com/akawula/DoraMatic/github/repositories"
+	"github.com/akawula/DoraMatic/internal/config"
 	"github.com/shurcooL/githubv4"
 )
 
 type Commit struct {
 	Id     githubv4.String
 	Commit struct {
-		Message githubv4.String
+		Message       githubv4.String
+		AuthoredDate  githubv4.String
+		CommittedDate githubv4.String
+		Additions     githubv4.Int
+		Deletions     githubv4.Int
 	}
 }
 
-type PullRequest struct {
-	Id          githubv4.String
-	Title       githubv4.String
+type Review struct {
+	Id     githubv4.String
+	Author struct {
+		Login githubv4.String
+	}
 	State       githubv4.String
-	Url         githubv4.String
-	MergedAt    githubv4.String
-	CreatedAt   githubv4.String
-	Additions   githubv4.Int
-	Deletions   githubv4.Int
-	HeadRefName githubv4.String
-	Author      struct {
+	SubmittedAt githubv4.String
+}
+
+type ReviewRequest struct {
+	ReviewRequestedEventFragment struct {
+		CreatedAt         githubv4.String
+		RequestedReviewer struct {
+			User struct {
+				Login githubv4.String
+			} `graphql:"... on User"`
+			Team struct {
+				Name githubv4.String
+			} `graphql:"... on Team"`
+		}
+	} `graphql:"... on ReviewRequestedEvent"`
+}
+
+// LabelEvent is a label added to or removed from the pull request, only one of the fragments is set.
+type LabelEvent struct {
+	LabeledEventFragment struct {
+		CreatedAt githubv4.String
+		Label     struct {
+			Name githubv4.String
+		}
+	} `graphql:"... on LabeledEvent"`
+	UnlabeledEventFragment struct {
+		CreatedAt githubv4.String
+		Label     struct {
+			Name githubv4.String
+		}
+	} `graphql:"... on UnlabeledEvent"`
+}
+
+// Issue is a GitHub issue the pull request closes when it's merged.
+type Issue struct {
+	Number     githubv4.Int
+	Url        githubv4.String
+	CreatedAt  githubv4.String
+	ClosedAt   githubv4.String
+	Repository struct {
+		NameWithOwner githubv4.String
+	}
+}
+
+type PullRequest struct {
+	Id           githubv4.String
+	Title        githubv4.String
+	Body         githubv4.String
+	State        githubv4.String
+	Url          githubv4.String
+	MergedAt     githubv4.String
+	ClosedAt     githubv4.String
+	CreatedAt    githubv4.String
+	Additions    githubv4.Int
+	Deletions    githubv4.Int
+	ChangedFiles githubv4.Int
+	HeadRefName  githubv4.String
+	MergeCommit  struct {
+		Oid          githubv4.String
+		Message      githubv4.String
+		AuthoredDate githubv4.String
+		Parents      struct {
+			TotalCount githubv4.Int
+		} `graphql:"parents(first: 1)"`
+	}
+	MergedBy struct {
+		Login githubv4.String
+	}
+	Author struct {
 		AvatarUrl githubv4.String
 		Login     githubv4.String
 	}
 	Repository repositories.Repository
-	Commits    struct {
+	Labels     struct {
+		Nodes []struct {
+			Name githubv4.String
+		}
+	} `graphql:"labels(first: 20)"`
+	Commits struct {
 		Nodes      []Commit
 		TotalCount githubv4.Int
-	} `graphql:"commits(first: 50)"`
+	} `graphql:"commits(first: $commitsFirst)"`
+	Reviews struct {
+		Nodes []Review
+	} `graphql:"reviews(first: 50)"`
 	TimelineItems struct {
-		Nodes []struct {
-			ReviewRequestedEventFragment struct {
-				CreatedAt githubv4.String
-			} `graphql:"... on ReviewRequestedEvent"`
-		}
+		Nodes      []ReviewRequest
 		TotalCount githubv4.Int
-	} `graphql:"timelineItems(itemTypes: REVIEW_REQUESTED_EVENT, first: 1)"`
+	} `graphql:"timelineItems(itemTypes: REVIEW_REQUESTED_EVENT, first: 25)"`
+	LabelEvents struct {
+		Nodes []LabelEvent
+	} `graphql:"labelEvents: timelineItems(itemTypes: [LABELED_EVENT, UNLABELED_EVENT], first: 25)"`
+	ClosingIssuesReferences struct {
+		Nodes []Issue
+	} `graphql:"closingIssuesReferences(first: 10)"`
 }
 
-func Get(org string, repo string, lastDBDate time.Time, logger *slog.Logger) ([]PullRequest, error) {
+const (
+	nodesPageSize      = 25 // pull requests refreshed per nodes query, each one brings its commits, reviews and timelines
+	pageSize           = 30
+	minPageSize        = 1
+	commitsPageSize    = 50
+	minCommitsPageSize = 5
+)
+
+// Get returns the pull requests in the configured states created since lastDBDate, newest first.
+func Get(cfg config.GitHub, org string, repo string, lastDBDate time.Time, logger *slog.Logger) ([]PullRequest, error) {
 	var q struct {
 		Repository struct {
 			PullRequests struct {
@@ -55,21 +144,28 @@ func Get(org string, repo string, lastDBDate time.Time, logger *slog.Logger) ([]
 					HasNextPage githubv4.Boolean
 					EndCursor   githubv4.String
 				}
-			} `graphql:"pullRequests(first:30, orderBy: {field: CREATED_AT, direction: DESC}, states: [MERGED, OPEN], after: $after)"`
+			} `graphql:"pullRequests(first: $first, orderBy: {field: CREATED_AT, direction: DESC}, states: $states, after: $after)"`
 		} `graphql:"repository(name: $name, owner: $login)"`
 	}
 
-	client := client.Get()
-	variables := map[string]interface{}{"login": githubv4.String(org), "name": githubv4.String(repo), "after": (*githubv4.String)(nil)}
+	c := client.Get(cfg)
+	variables := map[string]interface{}{"login": githubv4.String(org), "name": githubv4.String(repo), "after": (*githubv4.String)(nil), "first": githubv4.Int(pageSize), "commitsFirst": githubv4.Int(commitsPageSize), "states": states(cfg.PRStates)}
 	logger.Debug("Will do the pull reuqest query with params", "variables", variables)
 	results := []PullRequest{}
-	retries := 3
 	for {
-		err := client.Query(context.Background(), &q, variables)
+		err := client.Retry(client.DefaultBackoff, func() error {
+			err := c.Query(context.Background(), &q, variables)
+			if err != nil && isNodeLimitError(err) {
+				return client.Permanent(err) // a smaller query is needed, not a retry
+			}
+			if err != nil {
+				logger.Debug("Retrying fetching pull requests", "org", org, "repo", repo, "error", err)
+			}
+			return err
+		})
 		if err != nil {
-			if retries > 0 {
-				logger.Debug("Retrying fetching pull requests", "org", org, "repo", repo, "retries", retries, "error", err)
-				retries--
+			if isNodeLimitError(err) && downgrade(variables) {
+				logger.Warn("Query exceeded GitHub node limits, downgrading page sizes", "org", org, "repo", repo, "first", variables["first"], "commitsFirst", variables["commitsFirst"], "error", err)
 				continue
 			}
 			return nil, err
@@ -84,6 +180,98 @@ func Get(org string, repo string, lastDBDate time.Time, logger *slog.Logger) ([]
 	return results, nil
 }
 
+// GetByIds fetches the pull requests again by their node ids, the deleted ones are skipped.
+func GetByIds(cfg config.GitHub, ids []string) ([]PullRequest, error) {
+	nodes, err := client.Nodes[struct {
+		PullRequest PullRequest `graphql:"... on PullRequest"`
+	}](client.Get(cfg), ids, nodesPageSize, map[string]interface{}{"commitsFirst": githubv4.Int(commitsPageSize)})
+	if err != nil {
+		return nil, err
+	}
+
+	results := []PullRequest{}
+	for _, node := range nodes {
+		if len(node.PullRequest.Id) > 0 {
+			results = append(results, node.PullRequest)
+		}
+	}
+
+	return results, nil
+}
+
+func states(values []string) []githubv4.PullRequestState {
+	results := []githubv4.PullRequestState{}
+	for _, s := range values {
+		results = append(results, githubv4.PullRequestState(s))
+	}
+
+	return results
+}
+
+// isNodeLimitError reports whether GitHub rejected the query because of its size rather than a transient failure,
+// timeouts aren't matched, they go through the retries with the same page sizes.
+func isNodeLimitError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"resource_limited", "max_node_limit_exceeded", "node_limit", "resource limits", "exceeds the maximum limit"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// downgrade halves the pull requests page size first and the nested commits connection afterwards,
+// it returns false when both are already at their minimum and there is nothing left to shrink.
+func downgrade(variables map[string]interface{}) bool {
+	first := variables["first"].(githubv4.Int)
+	commitsFirst := variables["commitsFirst"].(githubv4.Int)
+	switch {
+	case first > minPageSize:
+		variables["first"] = max(first/2, minPageSize)
+	case commitsFirst > minCommitsPageSize:
+		variables["commitsFirst"] = max(commitsFirst/2, minCommitsPageSize)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// FirstApprovedAt returns when the pull request got its first approving review, empty when it wasn't approved.
+func (pr PullRequest) FirstApprovedAt() githubv4.String {
+	first := githubv4.String("")
+	for _, review := range pr.Reviews.Nodes {
+		if review.State == "APPROVED" && (len(first) == 0 || review.SubmittedAt < first) {
+			first = review.SubmittedAt
+		}
+	}
+
+	return first
+}
+
+// MergeMethod guesses how the pull request was merged, GitHub doesn't expose it: a merge commit has two parents,
+// a rebase replays the last commit keeping its message and author date while a squash writes a new commit.
+// It's empty when not merged or when the evidence is ambiguous: a squashed single commit looks like a rebased one
+// and a rebase can't be recognized when not all the commits were fetched.
+func (pr PullRequest) MergeMethod() string {
+	switch {
+	case len(pr.MergeCommit.Oid) == 0:
+		return ""
+	case pr.MergeCommit.Parents.TotalCount > 1:
+		return "merge"
+	case len(pr.Commits.Nodes) < 2 || int(pr.Commits.TotalCount) != len(pr.Commits.Nodes):
+		return ""
+	}
+
+	last := pr.Commits.Nodes[len(pr.Commits.Nodes)-1].Commit
+	if last.Message == pr.MergeCommit.Message && last.AuthoredDate == pr.MergeCommit.AuthoredDate {
+		return "rebase"
+	}
+
+	return "squash"
+}
+
 func checkDates(lastDbDate time.Time, ghDate githubv4.String) bool {
 	r, err := time.Parse(time.RFC3339, string(ghDate))
 	if err != nil {
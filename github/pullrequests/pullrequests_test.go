@@ -0,0 +1,109 @@
+package pullrequests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+func TestIsNodeLimitError(t *testing.T) {
+	tests := []struct {
+		err  string
+		want bool
+	}{
+		{"RESOURCE_LIMITED: the query exceeds the resource limits", true},
+		{"MAX_NODE_LIMIT_EXCEEDED: By the time this query traverses to the commits connection, it is requesting up to 550,000 possible nodes which exceeds the maximum limit of 500,000.", true},
+		{"Something went wrong while executing your query. This may be the result of a timeout, or it could be a GitHub bug.", false},
+		{"Post \"https://api.github.com/graphql\": context deadline exceeded (Client.Timeout exceeded while awaiting headers)", false},
+		{"non-200 OK status code: 504 Gateway Timeout body: \"\"", false},
+		{"Could not resolve to a Repository with the name 'org/repo'.", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			if got := isNodeLimitError(errors.New(tt.err)); got != tt.want {
+				t.Errorf("isNodeLimitError() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDowngrade(t *testing.T) {
+	tests := []struct {
+		name             string
+		first            githubv4.Int
+		commitsFirst     githubv4.Int
+		want             bool
+		wantFirst        githubv4.Int
+		wantCommitsFirst githubv4.Int
+	}{
+		{"pull requests are halved first", pageSize, commitsPageSize, true, pageSize / 2, commitsPageSize},
+		{"odd page size rounds down", 3, commitsPageSize, true, 1, commitsPageSize},
+		{"commits are halved once the pull requests are at the minimum", minPageSize, commitsPageSize, true, minPageSize, commitsPageSize / 2},
+		{"commits don't go under the minimum", minPageSize, minCommitsPageSize + 1, true, minPageSize, minCommitsPageSize},
+		{"nothing left to shrink", minPageSize, minCommitsPageSize, false, minPageSize, minCommitsPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variables := map[string]interface{}{"first": tt.first, "commitsFirst": tt.commitsFirst}
+			if got := downgrade(variables); got != tt.want {
+				t.Errorf("downgrade() = %t, want %t", got, tt.want)
+			}
+			if variables["first"] != tt.wantFirst || variables["commitsFirst"] != tt.wantCommitsFirst {
+				t.Errorf("first, commitsFirst = %v, %v, want %v, %v", variables["first"], variables["commitsFirst"], tt.wantFirst, tt.wantCommitsFirst)
+			}
+		})
+	}
+}
+
+// merged builds a pull request merged by a commit with the given parents, message and author date on top of commits,
+// total is how many commits the pull request has, fetched or not.
+func merged(parents int, message string, authoredDate string, commits []Commit, total int) PullRequest {
+	pr := PullRequest{}
+	pr.MergeCommit.Oid = "abc123"
+	pr.MergeCommit.Parents.TotalCount = githubv4.Int(parents)
+	pr.MergeCommit.Message = githubv4.String(message)
+	pr.MergeCommit.AuthoredDate = githubv4.String(authoredDate)
+	pr.Commits.Nodes = commits
+	pr.Commits.TotalCount = githubv4.Int(total)
+
+	return pr
+}
+
+func commit(message string, authoredDate string) Commit {
+	c := Commit{}
+	c.Commit.Message = githubv4.String(message)
+	c.Commit.AuthoredDate = githubv4.String(authoredDate)
+
+	return c
+}
+
+func TestMergeMethod(t *testing.T) {
+	two := []Commit{commit("feat: first", "2024-01-01T10:00:00Z"), commit("fix: second", "2024-01-01T11:00:00Z")}
+
+	tests := []struct {
+		name string
+		pr   PullRequest
+		want string
+	}{
+		{"not merged", PullRequest{}, ""},
+		{"merge commit", merged(2, "Merge pull request #1", "2024-01-02T00:00:00Z", two, 2), "merge"},
+		{"merge commit of a single commit", merged(2, "Merge pull request #1", "2024-01-02T00:00:00Z", two[:1], 1), "merge"},
+		{"rebase keeps the last commit", merged(1, "fix: second", "2024-01-01T11:00:00Z", two, 2), "rebase"},
+		{"squash writes a new commit", merged(1, "feat: first (#1)", "2024-01-02T00:00:00Z", two, 2), "squash"},
+		{"same message with another author date is a squash", merged(1, "fix: second", "2024-01-02T00:00:00Z", two, 2), "squash"},
+		{"single commit is ambiguous", merged(1, "feat: first", "2024-01-01T10:00:00Z", two[:1], 1), ""},
+		{"single commit squashed with a new message is ambiguous", merged(1, "feat: first (#1)", "2024-01-02T00:00:00Z", two[:1], 1), ""},
+		{"not all commits fetched", merged(1, "fix: second", "2024-01-01T11:00:00Z", two, 60), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pr.MergeMethod(); got != tt.want {
+				t.Errorf("MergeMethod() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,14 +2,17 @@ package repositories
 
 import (
 	"context"
+	"strings"
 
 	"github.com/akawula/DoraMatic/github/client"
-	"github.com/akawula/DoraMatic/github/organizations"
+	"github.com/akawula/DoraMatic/internal/config"
 	"github.com/shurcooL/githubv4"
 )
 
 type Repository struct {
+	Id              githubv4.String
 	Name            githubv4.String
+	IsArchived      githubv4.Boolean
 	PrimaryLanguage struct {
 		Name githubv4.String
 	}
@@ -18,15 +21,11 @@ type Repository struct {
 	}
 }
 
-func Get() ([]Repository, error) {
-	orgs, err := organizations.Get()
-	if err != nil {
-		return nil, err
-	}
-
+// Get returns the repositories of the given organizations, the archived ones too.
+func Get(cfg config.GitHub, orgs []string) ([]Repository, error) {
 	r := []Repository{}
 	for _, org := range orgs {
-		repos, err := getRepos(org)
+		repos, err := getRepos(cfg, org)
 		if err != nil {
 			return nil, err
 		}
@@ -36,7 +35,7 @@ func Get() ([]Repository, error) {
 	return r, nil
 }
 
-func getRepos(org string) ([]Repository, error) {
+func getRepos(cfg config.GitHub, org string) ([]Repository, error) {
 	var q struct {
 		Organization struct {
 			Repositories struct {
@@ -45,11 +44,11 @@ func getRepos(org string) ([]Repository, error) {
 					HasNextPage githubv4.Boolean
 					EndCursor   githubv4.String
 				}
-			} `graphql:"repositories(first: 100, isArchived: false, after: $after)"`
+			} `graphql:"repositories(first: 100, after: $after)"`
 		} `graphql:"organization(login: $organization)"`
 	}
 
-	client := client.Get()
+	client := client.Get(cfg)
 	variables := map[string]interface{}{"organization": githubv4.String(org), "after": (*githubv4.String)(nil)}
 	results := []Repository{}
 	for {
@@ -66,3 +65,24 @@ func getRepos(org string) ([]Repository, error) {
 
 	return results, nil
 }
+
+// GetId returns the node id of the repository, GitHub resolves the previous names of renamed and transferred repositories.
+func GetId(cfg config.GitHub, org string, name string) (string, error) {
+	var q struct {
+		Repository struct {
+			Id githubv4.String
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	c := client.Get(cfg)
+	variables := map[string]interface{}{"owner": githubv4.String(org), "name": githubv4.String(name)}
+	err := client.Retry(client.DefaultBackoff, func() error {
+		err := c.Query(context.Background(), &q, variables)
+		if err != nil && strings.Contains(err.Error(), "Could not resolve") {
+			return client.Permanent(err) // the repository was deleted
+		}
+		return err
+	})
+
+	return string(q.Repository.Id), err
+}
@@ -0,0 +1,90 @@
+package workflows
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/akawula/DoraMatic/github/client"
+	"github.com/akawula/DoraMatic/internal/config"
+)
+
+const perPage = 100
+
+type Run struct {
+	Id           int64     `json:"id"`
+	Name         string    `json:"name"`
+	HeadBranch   string    `json:"head_branch"`
+	HeadSha      string    `json:"head_sha"`
+	Event        string    `json:"event"`
+	Status       string    `json:"status"`
+	Conclusion   string    `json:"conclusion"`
+	HtmlUrl      string    `json:"html_url"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	RunStartedAt time.Time `json:"run_started_at"`
+	Repository   struct {
+		NodeId string `json:"node_id"`
+		Name   string `json:"name"`
+		Owner  struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+// Get returns the runs created since the given time of the repository's workflows named like the configured deploy workflows.
+func Get(cfg config.GitHub, org string, repo string, since time.Time) ([]Run, error) {
+	rest := client.GetREST(cfg)
+
+	var workflows struct {
+		Workflows []struct {
+			Id   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"workflows"`
+	}
+	if err := rest.Get(fmt.Sprintf("/repos/%s/%s/actions/workflows?per_page=%d", url.PathEscape(org), url.PathEscape(repo), perPage), &workflows); err != nil {
+		return nil, err
+	}
+
+	results := []Run{}
+	for _, workflow := range workflows.Workflows {
+		if !slices.Contains(cfg.DeployWorkflows, workflow.Name) {
+			continue
+		}
+
+		for page := 1; ; page++ {
+			var runs struct {
+				WorkflowRuns []Run `json:"workflow_runs"`
+			}
+			path := fmt.Sprintf("/repos/%s/%s/actions/workflows/%d/runs?per_page=%d&page=%d&created=%s", url.PathEscape(org), url.PathEscape(repo), workflow.Id, perPage, page, url.QueryEscape(">="+since.UTC().Format(time.RFC3339)))
+			if err := rest.Get(path, &runs); err != nil {
+				return nil, err
+			}
+
+			results = append(results, runs.WorkflowRuns...)
+			if len(runs.WorkflowRuns) < perPage {
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// FailureCategory groups the run conclusion for the change failure rate: success and failure are deploy outcomes,
+// infrastructure failures and cancelled runs say nothing about the change. It's empty while the run is in progress.
+func (r Run) FailureCategory() string {
+	switch r.Conclusion {
+	case "success":
+		return "success"
+	case "failure", "timed_out":
+		return "failure"
+	case "startup_failure":
+		return "infrastructure"
+	case "":
+		return ""
+	default: // cancelled, skipped, stale, neutral, action_required
+		return "cancelled"
+	}
+}
@@ -0,0 +1,258 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/akawula/DoraMatic/github/pullrequests"
+	"github.com/shurcooL/githubv4"
+)
+
+var states = []string{"OPEN", "MERGED", "CLOSED"}
+
+type Commit struct {
+	Id          string `json:"id"`
+	PrId        string `json:"pr_id"`
+	Message     string `json:"message"`
+	CommittedAt string `json:"committed_at"`
+	Additions   int    `json:"additions"`
+	Deletions   int    `json:"deletions"`
+}
+
+type Review struct {
+	Id          string `json:"id"`
+	PrId        string `json:"pr_id"`
+	Author      string `json:"author"`
+	State       string `json:"state"`
+	SubmittedAt string `json:"submitted_at"`
+}
+
+// PullRequest is a single record of the dump, dates are RFC3339.
+type PullRequest struct {
+	Id                string   `json:"id"`
+	Url               string   `json:"url"`
+	Title             string   `json:"title"`
+	State             string   `json:"state"`
+	Author            string   `json:"author"`
+	Additions         int      `json:"additions"`
+	Deletions         int      `json:"deletions"`
+	CreatedAt         string   `json:"created_at"`
+	MergedAt          string   `json:"merged_at"`
+	BranchName        string   `json:"branch_name"`
+	MergeCommitSha    string   `json:"merge_commit_sha"`
+	RepositoryOwner   string   `json:"repository_owner"`
+	RepositoryName    string   `json:"repository_name"`
+	ReviewRequestedAt string   `json:"review_requested_at"`
+	Commits           []Commit `json:"commits"`
+	Reviews           []Review `json:"reviews"`
+}
+
+// ReadJSON reads an array of pull requests with their commits and reviews nested.
+func ReadJSON(r io.Reader) ([]PullRequest, error) {
+	prs := []PullRequest{}
+	if err := json.NewDecoder(r).Decode(&prs); err != nil {
+		return nil, err
+	}
+
+	return prs, nil
+}
+
+// ReadCSV reads the pull requests, commits and reviews from separate files with a header row named like the JSON keys,
+// commits and reviews are optional (nil) and are attached to their pull request by pr_id.
+func ReadCSV(prsFile io.Reader, commitsFile io.Reader, reviewsFile io.Reader) ([]PullRequest, error) {
+	rows, err := readCSV(prsFile)
+	if err != nil {
+		return nil, fmt.Errorf("prs: %w", err)
+	}
+
+	prs := []PullRequest{}
+	byId := map[string]int{}
+	for i, row := range rows {
+		additions, err := atoi(row["additions"])
+		if err != nil {
+			return nil, fmt.Errorf("prs row %d: additions: %w", i+2, err)
+		}
+		deletions, err := atoi(row["deletions"])
+		if err != nil {
+			return nil, fmt.Errorf("prs row %d: deletions: %w", i+2, err)
+		}
+
+		byId[row["id"]] = len(prs)
+		prs = append(prs, PullRequest{
+			Id:                row["id"],
+			Url:               row["url"],
+			Title:             row["title"],
+			State:             row["state"],
+			Author:            row["author"],
+			Additions:         additions,
+			Deletions:         deletions,
+			CreatedAt:         row["created_at"],
+			MergedAt:          row["merged_at"],
+			BranchName:        row["branch_name"],
+			MergeCommitSha:    row["merge_commit_sha"],
+			RepositoryOwner:   row["repository_owner"],
+			RepositoryName:    row["repository_name"],
+			ReviewRequestedAt: row["review_requested_at"],
+		})
+	}
+
+	if commitsFile != nil {
+		rows, err := readCSV(commitsFile)
+		if err != nil {
+			return nil, fmt.Errorf("commits: %w", err)
+		}
+		for i, row := range rows {
+			idx, ok := byId[row["pr_id"]]
+			if !ok {
+				return nil, fmt.Errorf("commits row %d: unknown pr_id %q", i+2, row["pr_id"])
+			}
+			additions, err := atoi(row["additions"])
+			if err != nil {
+				return nil, fmt.Errorf("commits row %d: additions: %w", i+2, err)
+			}
+			deletions, err := atoi(row["deletions"])
+			if err != nil {
+				return nil, fmt.Errorf("commits row %d: deletions: %w", i+2, err)
+			}
+			prs[idx].Commits = append(prs[idx].Commits, Commit{Id: row["id"], PrId: row["pr_id"], Message: row["message"], CommittedAt: row["committed_at"], Additions: additions, Deletions: deletions})
+		}
+	}
+
+	if reviewsFile != nil {
+		rows, err := readCSV(reviewsFile)
+		if err != nil {
+			return nil, fmt.Errorf("reviews: %w", err)
+		}
+		for i, row := range rows {
+			idx, ok := byId[row["pr_id"]]
+			if !ok {
+				return nil, fmt.Errorf("reviews row %d: unknown pr_id %q", i+2, row["pr_id"])
+			}
+			prs[idx].Reviews = append(prs[idx].Reviews, Review{Id: row["id"], PrId: row["pr_id"], Author: row["author"], State: row["state"], SubmittedAt: row["submitted_at"]})
+		}
+	}
+
+	return prs, nil
+}
+
+// Validate returns every problem of the record, nil when it can be imported.
+func (pr PullRequest) Validate() error {
+	errs := []error{}
+	for field, value := range map[string]string{"id": pr.Id, "url": pr.Url, "title": pr.Title, "repository_owner": pr.RepositoryOwner, "repository_name": pr.RepositoryName} {
+		if len(value) == 0 {
+			errs = append(errs, fmt.Errorf("%s is required", field))
+		}
+	}
+	if !slices.Contains(states, pr.State) {
+		errs = append(errs, fmt.Errorf("state %q must be one of %v", pr.State, states))
+	}
+	errs = append(errs, date("created_at", pr.CreatedAt, true), date("merged_at", pr.MergedAt, pr.State == "MERGED"), date("review_requested_at", pr.ReviewRequestedAt, false))
+	for _, c := range pr.Commits {
+		if len(c.Id) == 0 {
+			errs = append(errs, errors.New("commit id is required"))
+		}
+		errs = append(errs, date("commit committed_at", c.CommittedAt, false))
+	}
+	for _, r := range pr.Reviews {
+		if len(r.Id) == 0 || len(r.State) == 0 {
+			errs = append(errs, errors.New("review id and state are required"))
+		}
+		errs = append(errs, date("review submitted_at", r.SubmittedAt, false))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ToPullRequest maps the record to the shape fetched from GitHub, so it's stored exactly like synced data.
+func (pr PullRequest) ToPullRequest() pullrequests.PullRequest {
+	result := pullrequests.PullRequest{
+		Id:          githubv4.String(pr.Id),
+		Url:         githubv4.String(pr.Url),
+		Title:       githubv4.String(pr.Title),
+		State:       githubv4.String(pr.State),
+		Additions:   githubv4.Int(pr.Additions),
+		Deletions:   githubv4.Int(pr.Deletions),
+		CreatedAt:   githubv4.String(pr.CreatedAt),
+		MergedAt:    githubv4.String(pr.MergedAt),
+		HeadRefName: githubv4.String(pr.BranchName),
+	}
+	result.MergeCommit.Oid = githubv4.String(pr.MergeCommitSha)
+	result.Author.Login = githubv4.String(pr.Author)
+	result.Repository.Name = githubv4.String(pr.RepositoryName)
+	result.Repository.Owner.Login = githubv4.String(pr.RepositoryOwner)
+
+	if len(pr.ReviewRequestedAt) > 0 {
+		request := pullrequests.ReviewRequest{}
+		request.ReviewRequestedEventFragment.CreatedAt = githubv4.String(pr.ReviewRequestedAt)
+		result.TimelineItems.Nodes = append(result.TimelineItems.Nodes, request)
+		result.TimelineItems.TotalCount = 1
+	}
+
+	for _, c := range pr.Commits {
+		commit := pullrequests.Commit{Id: githubv4.String(c.Id)}
+		commit.Commit.Message = githubv4.String(c.Message)
+		commit.Commit.CommittedDate = githubv4.String(c.CommittedAt)
+		commit.Commit.Additions = githubv4.Int(c.Additions)
+		commit.Commit.Deletions = githubv4.Int(c.Deletions)
+		result.Commits.Nodes = append(result.Commits.Nodes, commit)
+	}
+	result.Commits.TotalCount = githubv4.Int(len(pr.Commits))
+
+	for _, r := range pr.Reviews {
+		review := pullrequests.Review{Id: githubv4.String(r.Id), State: githubv4.String(r.State), SubmittedAt: githubv4.String(r.SubmittedAt)}
+		review.Author.Login = githubv4.String(r.Author)
+		result.Reviews.Nodes = append(result.Reviews.Nodes, review)
+	}
+
+	return result
+}
+
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("header row is missing")
+	}
+
+	rows := []map[string]string{}
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, column := range records[0] {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func date(field string, value string, required bool) error {
+	if len(value) == 0 {
+		if required {
+			return fmt.Errorf("%s is required", field)
+		}
+		return nil
+	}
+
+	if _, err := time.Parse(time.RFC3339, value); err != nil {
+		return fmt.Errorf("%s %q is not a RFC3339 date", field, value)
+	}
+
+	return nil
+}
+
+func atoi(s string) (int, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+type GitHub struct {
+	Token           string   // GITHUB_TOKEN
+	OrgsInclude     []string // GITHUB_ORGS_INCLUDE, lower cased
+	OrgsExclude     []string // GITHUB_ORGS_EXCLUDE, lower cased
+	DeployWorkflows []string // GITHUB_DEPLOY_WORKFLOWS, names of the Actions workflows whose runs are synced as deployments
+	PRStates        []string // GITHUB_PR_STATES, pull request states to sync, upper cased
+}
+
+type Postgres struct {
+	User          string        // POSTGRES_USER
+	Password      string        // POSTGRES_PASSWORD
+	DB            string        // POSTGRES_DB
+	Host          string        // POSTGRES_SERVICE_HOST
+	Port          string        // POSTGRES_SERVICE_PORT
+	ReplicaHost   string        // POSTGRES_REPLICA_SERVICE_HOST, optional
+	ReplicaPort   string        // POSTGRES_REPLICA_SERVICE_PORT, defaults to Port
+	ReplicaMaxLag time.Duration // POSTGRES_REPLICA_MAX_LAG in seconds
+}
+
+type Slack struct {
+	Token          string            // SLACK_TOKEN
+	Channel        string            // SLACK_CHANNEL, receives the security digest
+	StatusChannel  string            // SLACK_STATUS_CHANNEL, receives the run status
+	TeamChannels   map[string]string // SLACK_TEAM_CHANNELS, team=channel pairs, the team's pull requests go there instead of Channel
+	DigestChannels []string          // SLACK_DIGEST_CHANNELS, channels that get a single summary with the pull requests in its thread
+}
+
+type SMTP struct {
+	Host     string   // SMTP_HOST
+	Port     string   // SMTP_PORT
+	Username string   // SMTP_USERNAME, optional
+	Password string   // SMTP_PASSWORD
+	From     string   // SMTP_FROM
+	To       []string // SMTP_TO
+}
+
+type MSTeams struct {
+	WebhookURL string // TEAMS_WEBHOOK_URL
+}
+
+type Security struct {
+	Teams          []string // SECURITY_PR_TEAMS
+	Labels         []string // SECURITY_PR_LABELS
+	BranchPrefixes []string // SECURITY_PR_BRANCH_PREFIXES
+	TitleRegexes   []string // SECURITY_PR_TITLE_REGEXES, separated by `;;`
+	DependabotAll  bool     // SECURITY_PR_DEPENDABOT_ALL, every pull request opened by dependabot, version updates included
+}
+
+type Compliance struct {
+	Rules       []string // COMPLIANCE_RULES, any of checklist, risk, ticket; empty disables the checker
+	RiskHeading string   // COMPLIANCE_RISK_HEADING, heading of the section that has to be filled
+	TicketRegex string   // COMPLIANCE_TICKET_REGEX, reference to a ticket in the title, branch or body
+}
+
+type Events struct {
+	Broker        string // EVENTS_BROKER, nats; empty publishes nothing
+	URL           string // EVENTS_URL, host:port of the broker
+	SubjectPrefix string // EVENTS_SUBJECT_PREFIX, the subjects are <prefix>.<event type>
+}
+
+// RepoPattern matches repositories by org and slug, the slug may contain * wildcards.
+type RepoPattern struct {
+	Org  string
+	Slug string
+}
+
+type Config struct {
+	Debug          bool     // DEBUG
+	Notifiers      []string // NOTIFIER
+	GitHub         GitHub
+	Postgres       Postgres
+	Slack          Slack
+	SMTP           SMTP
+	MSTeams        MSTeams
+	Security       Security
+	Compliance     Compliance
+	Events         Events
+	IgnoredRepos   []RepoPattern // IGNORED_REPOS, comma separated org/slug, excluded from metrics but still synced
+	BlockingLabels []string      // BLOCKING_LABELS, lower cased, the time a pull request spends with any of them is reported as blocked
+}
+
+// defaultSecurityTeams are the teams whose pull requests were reported to security before the rules became configurable.
+var defaultSecurityTeams = []string{"pe-customer-journey", "PE Platform Insights", "Webstack", "Omnibus", "CSI", "pe-platform-fleet", "ie-deploy", "P&E - Team Domino", "Ares", "RD-Edge", "Golden", "RD - Production Engineering", "Security Engineering"}
+
+var notifiers = []string{"slack", "email", "msteams"}
+
+var complianceRules = []string{"checklist", "risk", "ticket"}
+
+var brokers = []string{"nats"}
+
+var prStates = []string{"OPEN", "MERGED", "CLOSED"}
+
+// Load reads the configuration from the environment, applies the defaults and validates the values that are set.
+// Required settings depend on the binary, use the Validate methods of the sections it needs.
+func Load() (*Config, error) {
+	errs := []error{}
+	c := &Config{
+		Debug:     os.Getenv("DEBUG") == "1",
+		Notifiers: lower(split(os.Getenv("NOTIFIER"), ",")),
+		GitHub: GitHub{
+			Token:           os.Getenv("GITHUB_TOKEN"),
+			OrgsInclude:     lower(split(os.Getenv("GITHUB_ORGS_INCLUDE"), ",")),
+			OrgsExclude:     lower(split(os.Getenv("GITHUB_ORGS_EXCLUDE"), ",")),
+			DeployWorkflows: split(os.Getenv("GITHUB_DEPLOY_WORKFLOWS"), ","),
+			PRStates:        upper(split(os.Getenv("GITHUB_PR_STATES"), ",")),
+		},
+		Postgres: Postgres{
+			User:          os.Getenv("POSTGRES_USER"),
+			Password:      os.Getenv("POSTGRES_PASSWORD"),
+			DB:            os.Getenv("POSTGRES_DB"),
+			Host:          os.Getenv("POSTGRES_SERVICE_HOST"),
+			Port:          withDefault(os.Getenv("POSTGRES_SERVICE_PORT"), "5432"),
+			ReplicaHost:   os.Getenv("POSTGRES_REPLICA_SERVICE_HOST"),
+			ReplicaMaxLag: seconds("POSTGRES_REPLICA_MAX_LAG", 30*time.Second, &errs),
+		},
+		Slack: Slack{
+			Token:          os.Getenv("SLACK_TOKEN"),
+			Channel:        withDefault(os.Getenv("SLACK_CHANNEL"), "UE9M08BLP"),
+			StatusChannel:  withDefault(os.Getenv("SLACK_STATUS_CHANNEL"), "UJ36ACNUD"),
+			DigestChannels: split(os.Getenv("SLACK_DIGEST_CHANNELS"), ","),
+		},
+		SMTP: SMTP{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     withDefault(os.Getenv("SMTP_PORT"), "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+			To:       split(os.Getenv("SMTP_TO"), ","),
+		},
+		MSTeams: MSTeams{
+			WebhookURL: os.Getenv("TEAMS_WEBHOOK_URL"),
+		},
+		Security: Security{
+			Teams:          split(os.Getenv("SECURITY_PR_TEAMS"), ","),
+			Labels:         split(os.Getenv("SECURITY_PR_LABELS"), ","),
+			BranchPrefixes: split(os.Getenv("SECURITY_PR_BRANCH_PREFIXES"), ","),
+			TitleRegexes:   split(os.Getenv("SECURITY_PR_TITLE_REGEXES"), ";;"),
+			DependabotAll:  os.Getenv("SECURITY_PR_DEPENDABOT_ALL") == "1",
+		},
+		Compliance: Compliance{
+			Rules:       lower(split(os.Getenv("COMPLIANCE_RULES"), ",")),
+			RiskHeading: withDefault(os.Getenv("COMPLIANCE_RISK_HEADING"), "risk"),
+			TicketRegex: withDefault(os.Getenv("COMPLIANCE_TICKET_REGEX"), `[A-Z][A-Z0-9]+-[0-9]+|#[0-9]+`),
+		},
+		Events: Events{
+			Broker:        strings.ToLower(strings.TrimSpace(os.Getenv("EVENTS_BROKER"))),
+			URL:           os.Getenv("EVENTS_URL"),
+			SubjectPrefix: withDefault(os.Getenv("EVENTS_SUBJECT_PREFIX"), "doramatic"),
+		},
+		BlockingLabels: lower(split(os.Getenv("BLOCKING_LABELS"), ",")),
+	}
+	for _, r := range split(os.Getenv("IGNORED_REPOS"), ",") {
+		org, slug, ok := strings.Cut(r, "/")
+		if !ok || len(org) == 0 || len(slug) == 0 {
+			errs = append(errs, fmt.Errorf("IGNORED_REPOS: expected org/slug, got %q", r))
+			continue
+		}
+		c.IgnoredRepos = append(c.IgnoredRepos, RepoPattern{Org: org, Slug: slug})
+	}
+
+	c.Slack.TeamChannels = map[string]string{}
+	for _, pair := range split(os.Getenv("SLACK_TEAM_CHANNELS"), ",") {
+		team, channel, ok := strings.Cut(pair, "=")
+		if team, channel = strings.TrimSpace(team), strings.TrimSpace(channel); !ok || len(team) == 0 || len(channel) == 0 {
+			errs = append(errs, fmt.Errorf("SLACK_TEAM_CHANNELS: expected team=channel, got %q", pair))
+			continue
+		}
+		c.Slack.TeamChannels[team] = channel
+	}
+
+	c.Postgres.ReplicaPort = withDefault(os.Getenv("POSTGRES_REPLICA_SERVICE_PORT"), c.Postgres.Port)
+
+	if len(c.GitHub.PRStates) == 0 {
+		c.GitHub.PRStates = []string{"MERGED", "OPEN"}
+	}
+	for _, s := range c.GitHub.PRStates {
+		if !slices.Contains(prStates, s) {
+			errs = append(errs, fmt.Errorf("GITHUB_PR_STATES: unknown state %q, expected one of %v", s, prStates))
+		}
+	}
+
+	if len(c.Notifiers) == 0 {
+		c.Notifiers = []string{"slack"}
+	}
+	for _, n := range c.Notifiers {
+		if !slices.Contains(notifiers, n) {
+			errs = append(errs, fmt.Errorf("NOTIFIER: unknown notifier %q, expected one of %v", n, notifiers))
+		}
+	}
+
+	for _, r := range c.Compliance.Rules {
+		if !slices.Contains(complianceRules, r) {
+			errs = append(errs, fmt.Errorf("COMPLIANCE_RULES: unknown rule %q, expected one of %v", r, complianceRules))
+		}
+	}
+	if _, err := regexp.Compile(c.Compliance.TicketRegex); err != nil {
+		errs = append(errs, fmt.Errorf("COMPLIANCE_TICKET_REGEX: %w", err))
+	}
+
+	if len(c.Events.Broker) > 0 && !slices.Contains(brokers, c.Events.Broker) {
+		errs = append(errs, fmt.Errorf("EVENTS_BROKER: unknown broker %q, expected one of %v", c.Events.Broker, brokers))
+	}
+
+	if len(c.Security.Teams) == 0 {
+		c.Security.Teams = defaultSecurityTeams
+	}
+
+	return c, errors.Join(errs...)
+}
+
+func (g GitHub) Validate() error {
+	return required([]setting{{"GITHUB_TOKEN", g.Token}})
+}
+
+func (p Postgres) Validate() error {
+	return required([]setting{{"POSTGRES_USER", p.User}, {"POSTGRES_DB", p.DB}, {"POSTGRES_SERVICE_HOST", p.Host}})
+}
+
+func (s Slack) Validate() error {
+	return required([]setting{{"SLACK_TOKEN", s.Token}})
+}
+
+func (s SMTP) Validate() error {
+	err := required([]setting{{"SMTP_HOST", s.Host}, {"SMTP_FROM", s.From}})
+	if len(s.To) == 0 {
+		err = errors.Join(err, errors.New("SMTP_TO env is required"))
+	}
+
+	return err
+}
+
+func (t MSTeams) Validate() error {
+	return required([]setting{{"TEAMS_WEBHOOK_URL", t.WebhookURL}})
+}
+
+// Validate requires the broker address when publishing is enabled.
+func (e Events) Validate() error {
+	if len(e.Broker) == 0 {
+		return nil
+	}
+
+	return required([]setting{{"EVENTS_URL", e.URL}})
+}
+
+// ValidateNotifiers checks the settings of every configured notifier.
+func (c *Config) ValidateNotifiers() error {
+	errs := []error{}
+	for _, n := range c.Notifiers {
+		switch n {
+		case "slack":
+			errs = append(errs, c.Slack.Validate())
+		case "email":
+			errs = append(errs, c.SMTP.Validate())
+		case "msteams":
+			errs = append(errs, c.MSTeams.Validate())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LogValue dumps the configuration with the secrets redacted, so it's safe to log it at startup.
+func (c *Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("debug", c.Debug),
+		slog.Any("notifiers", c.Notifiers),
+		slog.Group("github", "token", redact(c.GitHub.Token), "orgsInclude", c.GitHub.OrgsInclude, "orgsExclude", c.GitHub.OrgsExclude, "deployWorkflows", c.GitHub.DeployWorkflows, "prStates", c.GitHub.PRStates),
+		slog.Group("postgres", "user", c.Postgres.User, "password", redact(c.Postgres.Password), "db", c.Postgres.DB, "host", c.Postgres.Host, "port", c.Postgres.Port, "replicaHost", c.Postgres.ReplicaHost, "replicaPort", c.Postgres.ReplicaPort, "replicaMaxLag", c.Postgres.ReplicaMaxLag),
+		slog.Group("slack", "token", redact(c.Slack.Token), "channel", c.Slack.Channel, "statusChannel", c.Slack.StatusChannel, "teamChannels", c.Slack.TeamChannels, "digestChannels", c.Slack.DigestChannels),
+		slog.Group("smtp", "host", c.SMTP.Host, "port", c.SMTP.Port, "username", c.SMTP.Username, "password", redact(c.SMTP.Password), "from", c.SMTP.From, "to", c.SMTP.To),
+		slog.Group("msteams", "webhookURL", redact(c.MSTeams.WebhookURL)),
+		slog.Group("security", "teams", c.Security.Teams, "labels", c.Security.Labels, "branchPrefixes", c.Security.BranchPrefixes, "titleRegexes", c.Security.TitleRegexes, "dependabotAll", c.Security.DependabotAll),
+		slog.Group("events", "broker", c.Events.Broker, "url", c.Events.URL, "subjectPrefix", c.Events.SubjectPrefix),
+		slog.Any("ignoredRepos", c.IgnoredRepos),
+		slog.Any("blockingLabels", c.BlockingLabels),
+		slog.Group("compliance", "rules", c.Compliance.Rules, "riskHeading", c.Compliance.RiskHeading, "ticketRegex", c.Compliance.TicketRegex),
+	)
+}
+
+func redact(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	return redacted
+}
+
+// setting is an env variable and its value, a slice of them keeps the errors of required in a stable order.
+type setting struct {
+	env   string
+	value string
+}
+
+func required(settings []setting) error {
+	errs := []error{}
+	for _, s := range settings {
+		if len(s.value) == 0 {
+			errs = append(errs, fmt.Errorf("%s env is required", s.env))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func seconds(env string, def time.Duration, errs *[]error) time.Duration {
+	s := os.Getenv(env)
+	if len(s) == 0 {
+		return def
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		*errs = append(*errs, fmt.Errorf("%s: expected a number of seconds, got %q", env, s))
+		return def
+	}
+
+	return time.Duration(v) * time.Second
+}
+
+func withDefault(s string, def string) string {
+	if len(s) == 0 {
+		return def
+	}
+
+	return s
+}
+
+func split(s string, sep string) []string {
+	results := []string{}
+	for _, v := range strings.Split(s, sep) {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			results = append(results, v)
+		}
+	}
+
+	return results
+}
+
+func lower(values []string) []string {
+	for i, v := range values {
+		values[i] = strings.ToLower(v)
+	}
+
+	return values
+}
+
+func upper(values []string) []string {
+	for i, v := range values {
+		values[i] = strings.ToUpper(v)
+	}
+
+	return values
+}
@@ -0,0 +1,120 @@
+package msteams
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/akawula/DoraMatic/store"
+)
+
+func templatePullRequest(pr store.SecurityPR) map[string]interface{} {
+	facts := []map[string]interface{}{
+		{"title": "Repository", "value": pr.RepositoryName},
+		{"title": "Changes", "value": fmt.Sprintf("+%d -%d", pr.Additions, pr.Deletions)},
+		{"title": "Author", "value": pr.Author},
+		{"title": "State", "value": pr.State},
+		{"title": "Created At", "value": pr.CreatedAt},
+	}
+	if pr.State == "MERGED" {
+		facts[len(facts)-1] = map[string]interface{}{"title": "Merged At", "value": pr.MergedAt.String}
+	}
+
+	return map[string]interface{}{
+		"type":      "Container",
+		"separator": true,
+		"items": []map[string]interface{}{
+			{
+				"type":   "TextBlock",
+				"text":   pr.Title,
+				"weight": "bolder",
+				"wrap":   true,
+			},
+			{
+				"type":  "FactSet",
+				"facts": facts,
+			},
+			{
+				"type": "ActionSet",
+				"actions": []map[string]interface{}{
+					{
+						"type":  "Action.OpenUrl",
+						"title": "Open",
+						"url":   pr.Url,
+					},
+				},
+			},
+		},
+	}
+}
+
+type MSTeams struct {
+	cfg config.MSTeams
+}
+
+func New(cfg config.MSTeams) *MSTeams {
+	return &MSTeams{cfg: cfg}
+}
+
+func (t *MSTeams) SendMessage(prs []store.SecurityPR) error {
+	body := []map[string]interface{}{
+		{
+			"type": "TextBlock",
+			"text": "Looks like there were new Pull Requests yesterday",
+			"wrap": true,
+		},
+	}
+
+	for _, pr := range prs {
+		body = append(body, templatePullRequest(pr))
+	}
+
+	errs := []error{}
+	for c := range slices.Chunk(body, 25) { // keep every card below the webhook payload limit
+		errs = append(errs, t.sendCard(c))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *MSTeams) sendCard(body []map[string]interface{}) error {
+	if err := t.cfg.Validate(); err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			},
+		},
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(t.cfg.WebhookURL, "application/json", bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Teams webhook returned non-2xx status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/akawula/DoraMatic/email"
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/akawula/DoraMatic/msteams"
+	"github.com/akawula/DoraMatic/slack"
+	"github.com/akawula/DoraMatic/store"
+)
+
+// SendMessageFunc delivers the security pull requests to a single channel.
+type SendMessageFunc func(prs []store.SecurityPR) error
+
+// Get returns the senders of the notifiers configured in NOTIFIER.
+func Get(cfg *config.Config) ([]SendMessageFunc, error) {
+	senders := []SendMessageFunc{}
+	for _, name := range cfg.Notifiers {
+		switch name {
+		case "slack":
+			senders = append(senders, slack.New(cfg.Slack).SendMessage)
+		case "email":
+			senders = append(senders, email.New(cfg.SMTP).SendMessage)
+		case "msteams":
+			senders = append(senders, msteams.New(cfg.MSTeams).SendMessage)
+		default:
+			return nil, fmt.Errorf("unknown notifier %q", name)
+		}
+	}
+
+	return senders, nil
+}
+
+// SendMessage fans the pull requests out to every configured channel, a failing channel doesn't stop the others.
+func SendMessage(cfg *config.Config, prs []store.SecurityPR) error {
+	senders, err := Get(cfg)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, send := range senders {
+		errs = append(errs, send(prs))
+	}
+
+	return errors.Join(errs...)
+}
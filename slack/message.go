@@ -5,10 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
-	"os"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/akawula/DoraMatic/internal/config"
 	"github.com/akawula/DoraMatic/store"
 )
 
@@ -42,30 +46,105 @@ func templatePullRequest(pr store.SecurityPR) []map[string]interface{} {
 	}
 }
 
-func SendMessage(prs []store.SecurityPR) {
-	initialBlock := []map[string]interface{}{
+const (
+	// Slack allows about one message per second per channel
+	channelInterval = time.Second
+	maxRetries      = 3
+	digestRepos     = 20 // repositories listed in the digest summary
+)
+
+type message struct {
+	channel string
+	blocks  []map[string]interface{}
+	parent  bool // the digest summary, the following replies of the channel go to its thread
+	reply   bool
+}
+
+type Slack struct {
+	cfg      config.Slack
+	lastSent map[string]time.Time
+	threads  map[string]string // ts of the last digest summary per channel
+}
+
+func New(cfg config.Slack) *Slack {
+	return &Slack{cfg: cfg, lastSent: map[string]time.Time{}, threads: map[string]string{}}
+}
+
+// templateDigest summarizes the pull requests by repository, the pull requests themselves go to its thread.
+func templateDigest(prs []store.SecurityPR) []map[string]interface{} {
+	byRepo := map[string]int{}
+	for _, pr := range prs {
+		byRepo[pr.RepositoryOwner+"/"+pr.RepositoryName]++
+	}
+	repos := slices.SortedFunc(maps.Keys(byRepo), func(a, b string) int {
+		if byRepo[a] != byRepo[b] {
+			return byRepo[b] - byRepo[a]
+		}
+		return strings.Compare(a, b)
+	})
+
+	m := fmt.Sprintf("*%d new security Pull Requests yesterday*, details in the thread", len(prs))
+	for i, repo := range repos {
+		if i == digestRepos {
+			m += fmt.Sprintf("\n_and %d more repositories_", len(repos)-digestRepos)
+			break
+		}
+		m += fmt.Sprintf("\n*%s*: %d", repo, byRepo[repo])
+	}
+
+	return []map[string]interface{}{
 		{
 			"type": "section",
 			"text": map[string]interface{}{
-				"type":  "plain_text",
-				"emoji": true,
-				"text":  "Looks like there were new Pull Requests yesterday",
+				"type": "mrkdwn",
+				"text": m,
 			},
 		},
-		{
-			"type": "divider",
-		},
 	}
+}
 
-	for _, pr := range prs {
-		initialBlock = append(initialBlock, templatePullRequest(pr)...)
-	}
+func (s *Slack) SendMessage(prs []store.SecurityPR) error {
+	byChannel := s.route(prs)
+	channels := slices.Sorted(maps.Keys(byChannel))
+
+	queue := []message{}
+	for _, channel := range channels {
+		if slices.Contains(s.cfg.DigestChannels, channel) {
+			queue = append(queue, message{channel: channel, blocks: templateDigest(byChannel[channel]), parent: true})
+			blocks := []map[string]interface{}{}
+			for _, pr := range byChannel[channel] {
+				blocks = append(blocks, templatePullRequest(pr)...)
+			}
+			for c := range slices.Chunk(blocks, 50) {
+				queue = append(queue, message{channel: channel, blocks: c, reply: true})
+			}
+			continue
+		}
+
+		initialBlock := []map[string]interface{}{
+			{
+				"type": "section",
+				"text": map[string]interface{}{
+					"type":  "plain_text",
+					"emoji": true,
+					"text":  "Looks like there were new Pull Requests yesterday",
+				},
+			},
+			{
+				"type": "divider",
+			},
+		}
 
-	for c := range slices.Chunk(initialBlock, 50) {
-		sendMesasge(c, "UE9M08BLP")
+		for _, pr := range byChannel[channel] {
+			initialBlock = append(initialBlock, templatePullRequest(pr)...)
+		}
+
+		for c := range slices.Chunk(initialBlock, 50) {
+			queue = append(queue, message{channel: channel, blocks: c})
+		}
 	}
 
-	sendMesasge([]map[string]interface{}{
+	queue = append(queue, message{channel: s.cfg.StatusChannel, blocks: []map[string]interface{}{
 		{
 			"type": "section",
 			"text": map[string]interface{}{
@@ -74,33 +153,99 @@ func SendMessage(prs []store.SecurityPR) {
 				"text":  "Doramatic success!",
 			},
 		},
-	}, "UJ36ACNUD")
+	}})
+
+	return s.flush(queue)
+}
+
+// route groups the pull requests by the channels of their author's teams, the ones without a team channel go
+// to the default channel, which always gets the digest even if it's empty.
+func (s *Slack) route(prs []store.SecurityPR) map[string][]store.SecurityPR {
+	results := map[string][]store.SecurityPR{s.cfg.Channel: {}}
+	for _, pr := range prs {
+		channels := []string{}
+		for _, team := range pr.Teams {
+			if channel, ok := s.cfg.TeamChannels[team]; ok && !slices.Contains(channels, channel) {
+				channels = append(channels, channel)
+			}
+		}
+		if len(channels) == 0 {
+			channels = append(channels, s.cfg.Channel)
+		}
+
+		for _, channel := range channels {
+			results[channel] = append(results[channel], pr)
+		}
+	}
+
+	return results
+}
+
+// flush sends the queued messages in order, a failed message doesn't stop the rest and all the errors are returned.
+func (s *Slack) flush(queue []message) error {
+	errs := []error{}
+	for _, m := range queue {
+		if err := s.send(m); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %w", m.channel, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// send paces the messages per channel and retries the rate limited ones after the time Slack asked for.
+func (s *Slack) send(m message) error {
+	if wait := time.Until(s.lastSent[m.channel].Add(channelInterval)); wait > 0 {
+		time.Sleep(wait)
+	}
+	defer func() { s.lastSent[m.channel] = time.Now() }()
+
+	threadTs := ""
+	if m.reply {
+		threadTs = s.threads[m.channel] // empty when the summary failed, the replies are posted in the channel then
+	}
+
+	for attempt := 0; ; attempt++ {
+		ts, retryAfter, err := s.sendMesasge(m.blocks, m.channel, threadTs)
+		if m.parent && err == nil {
+			s.threads[m.channel] = ts
+		}
+		if retryAfter == 0 || attempt == maxRetries {
+			return err
+		}
+		time.Sleep(retryAfter)
+	}
 }
 
-func sendMesasge(blocks []map[string]interface{}, channel string) error {
+// sendMesasge posts a single message (to the thread when threadTs is set), it returns the ts of the message
+// and how long to wait before retrying when Slack rate limited it.
+func (s *Slack) sendMesasge(blocks []map[string]interface{}, channel string, threadTs string) (string, time.Duration, error) {
 	// Message payload
 	payload := map[string]interface{}{
 		"channel": channel,
 		"blocks":  blocks,
 	}
+	if len(threadTs) > 0 {
+		payload["thread_ts"] = threadTs
+	}
 
 	// Your Slack Bot Token
-	token := os.Getenv("SLACK_TOKEN")
+	token := s.cfg.Token
 	if len(token) == 0 {
-		return errors.New("SLACK_TOKEN env is required")
+		return "", 0, errors.New("SLACK_TOKEN env is required")
 	}
 	// Slack API endpoint for sending messages
 	url := "https://slack.com/api/chat.postMessage"
 	// Convert payload to JSON
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return "", 0, err
 	}
 
 	// Create HTTP request
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payloadBytes))
 	if err != nil {
-		return err
+		return "", 0, err
 	}
 
 	// Set headers
@@ -111,18 +256,34 @@ func sendMesasge(blocks []map[string]interface{}, channel string) error {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return "", 0, err
 	}
 	defer resp.Body.Close()
 
 	// Check response
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil || retryAfter < 1 {
+			retryAfter = 1
+		}
+		return "", time.Duration(retryAfter) * time.Second, errors.New("Slack API rate limited the message")
+	}
 	if resp.StatusCode != http.StatusOK {
-		return errors.New(fmt.Sprintf("Slack API returned non-200 status code: %d\n", resp.StatusCode))
+		return "", 0, fmt.Errorf("Slack API returned non-200 status code: %d", resp.StatusCode)
 	}
 
-	// Parse and print response
-	var response map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&response)
+	// Slack reports most of the failures in the body with a 200
+	var response struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+		Ts    string `json:"ts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", 0, err
+	}
+	if !response.Ok {
+		return "", 0, fmt.Errorf("Slack API returned an error: %s", response.Error)
+	}
 
-	return nil
+	return response.Ts, 0, nil
 }
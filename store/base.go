@@ -2,11 +2,18 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/akawula/DoraMatic/compliance"
+	"github.com/akawula/DoraMatic/events"
+	"github.com/akawula/DoraMatic/github/organizations"
 	"github.com/akawula/DoraMatic/github/pullrequests"
 	"github.com/akawula/DoraMatic/github/repositories"
+	"github.com/akawula/DoraMatic/github/workflows"
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/lib/pq"
 )
 
 type SecurityPR struct {
@@ -21,25 +28,89 @@ type SecurityPR struct {
 	MergedAt        sql.NullString `db:"merged_at"`
 	Url             string
 	Id              string
+	Teams           pq.StringArray // teams of the author
 }
 
-type Store interface {
+// IngestStore is used by the sync to write the data fetched from GitHub.
+type IngestStore interface {
 	Close()
-	GetRepos(page int, search string) ([]DBRepository, int, error)
 	SaveRepos([]repositories.Repository) error
 	GetLastPRDate(org string, repo string) time.Time
-	SavePullRequest(prs []pullrequests.PullRequest) (err error)
-	GetAllRepos() ([]DBRepository, error)
+	GetOldestOpenPRDate(org string, repo string) (time.Time, bool)
+	GetOpenPRIds(orgs []string) ([]string, error)
+	SavePullRequest(prs []pullrequests.PullRequest) (SaveResult, error)
+	SaveCompliance(results []compliance.Result) error
+	GetLastWorkflowRunDate(org string, repo string) time.Time
+	SaveWorkflowRuns(runs []workflows.Run) error
 	SaveTeams(teams map[string][]string) error
-	FetchSecurityPullRequests() ([]SecurityPR, error)
+	SaveIgnoredRepos(patterns []config.RepoPattern) error
+	SaveBlockingLabels(labels []string) error
+	SaveIdentities(identities []organizations.Identity) error
+	RecomputeDerivedColumns() (int64, error)
+	SyncRepositoryRenames() (int64, error)
+	GetUnlinkedRepos() ([]DBRepository, error)
+	SaveRepositoryAlias(id string, org string, slug string) error
+	BackfillJiraRefs() (int, error)
+	CheckConsistency() (Consistency, error)
+	GetInconsistentPRIds() ([]string, error)
+	SetPublisher(publisher events.Publisher)
+}
+
+// QueryStore is used by the readers: dashboards and notifications.
+type QueryStore interface {
+	Close()
+	GetRepos(page int, search string) ([]DBRepository, int, error)
+	GetAllRepos() ([]DBRepository, error)
+	ValidateSecurityRules(rules SecurityRules) (SecurityRules, error)
+	FetchSecurityPullRequests(rules SecurityRules) ([]SecurityPR, error)
+}
+
+type Store interface {
+	IngestStore
+	QueryStore
+	CheckSchema() error
+}
+
+// SaveResult counts what SavePullRequest did with the pull requests, the skipped ones couldn't be saved.
+type SaveResult struct {
+	Inserted int
+	Updated  int
+	Skipped  int
+	Errors   []PRError
+}
+
+type PRError struct {
+	PrId string
+	Err  error
+}
+
+// Err joins the errors of the skipped pull requests, nil when none was skipped.
+func (r SaveResult) Err() error {
+	errs := []error{}
+	for _, e := range r.Errors {
+		errs = append(errs, fmt.Errorf("pr %s: %w", e.PrId, e.Err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// GhostLogin is the author of pull requests and reviews whose GitHub account was deleted, like GitHub shows them.
+const GhostLogin = "ghost"
+
+func normalizeLogin(login string) string {
+	if len(login) == 0 {
+		return GhostLogin
+	}
+
+	return login
 }
 
 func getQueryRepos(search string) (string, string) {
 	s := `SELECT org, slug, language `
 	c := `SELECT count(*) as total `
-	q := `FROM repositories`
+	q := `FROM repositories WHERE NOT archived`
 	if len(search) > 0 {
-		q = fmt.Sprintf(`FROM repositories WHERE slug LIKE '%%%s%%'`, search)
+		q = fmt.Sprintf(`FROM repositories WHERE NOT archived AND slug LIKE '%%%s%%'`, search)
 	}
 
 	return s + q + " ORDER by slug, org", c + q
@@ -0,0 +1,57 @@
+package store
+
+// Consistency counts the rows whose references are broken. A pull request and its children are saved together,
+// but the tables have no foreign keys, so rows orphaned by older syncs or manual deletes are only found here.
+type Consistency struct {
+	OrphanCommits        int `db:"orphan_commits" json:"orphan_commits"`
+	OrphanReviews        int `db:"orphan_reviews" json:"orphan_reviews"`
+	OrphanReviewRequests int `db:"orphan_review_requests" json:"orphan_review_requests"`
+	OrphanJiraRefs       int `db:"orphan_jira_refs" json:"orphan_jira_refs"`
+	OrphanLabelEvents    int `db:"orphan_label_events" json:"orphan_label_events"`
+	OrphanIssueLinks     int `db:"orphan_issue_links" json:"orphan_issue_links"`
+	PRsWithoutRepository int `db:"prs_without_repository" json:"prs_without_repository"`
+}
+
+func (c Consistency) Total() int {
+	return c.OrphanCommits + c.OrphanReviews + c.OrphanReviewRequests + c.OrphanJiraRefs + c.OrphanLabelEvents + c.OrphanIssueLinks + c.PRsWithoutRepository
+}
+
+// prsWithoutRepository are the pull requests of the synced organizations whose repository, archived ones included,
+// isn't stored under their id or name; the ones of excluded organizations aren't expected to have one.
+const prsWithoutRepository = `SELECT p.id FROM prs p
+WHERE EXISTS (SELECT 1 FROM repositories r WHERE r.org = p.repository_owner)
+AND NOT EXISTS (SELECT 1 FROM repositories r WHERE r.id = p.repository_id OR (r.org = p.repository_owner AND r.slug = p.repository_name))`
+
+// CheckConsistency counts the commits, reviews, review requests, JIRA references, label events and issue links
+// without their pull request and the pull requests of repositories that aren't stored (anymore).
+func (p *Postgres) CheckConsistency() (Consistency, error) {
+	c := Consistency{}
+	err := p.db.Get(&c, `SELECT
+    (SELECT count(*) FROM commits c WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = c.pr_id)) AS orphan_commits,
+    (SELECT count(*) FROM reviews r WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = r.pr_id)) AS orphan_reviews,
+    (SELECT count(*) FROM review_requests r WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = r.pr_id)) AS orphan_review_requests,
+    (SELECT count(*) FROM pr_jira_refs j WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = j.pr_id)) AS orphan_jira_refs,
+    (SELECT count(*) FROM pr_label_events e WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = e.pr_id)) AS orphan_label_events,
+    (SELECT count(*) FROM pr_issue_links l WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = l.pr_id)) AS orphan_issue_links,
+    (SELECT count(*) FROM (`+prsWithoutRepository+`) w) AS prs_without_repository`)
+	if err != nil {
+		p.Logger.Error("can't check the data consistency", "error", err)
+	}
+
+	return c, wrapErr(err)
+}
+
+// GetInconsistentPRIds returns the pull requests worth fetching again: the ones the orphaned rows point at
+// and the ones of repositories that aren't stored.
+func (p *Postgres) GetInconsistentPRIds() ([]string, error) {
+	ids := []string{}
+	err := p.db.Select(&ids, `SELECT pr_id FROM commits c WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = c.pr_id)
+UNION SELECT pr_id FROM reviews r WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = r.pr_id)
+UNION SELECT pr_id FROM review_requests r WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = r.pr_id)
+UNION SELECT pr_id FROM pr_jira_refs j WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = j.pr_id)
+UNION SELECT pr_id FROM pr_label_events e WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = e.pr_id)
+UNION SELECT pr_id FROM pr_issue_links l WHERE NOT EXISTS (SELECT 1 FROM prs p WHERE p.id = l.pr_id)
+UNION `+prsWithoutRepository)
+
+	return ids, wrapErr(err)
+}
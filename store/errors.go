@@ -0,0 +1,37 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Errors returned by the store wrap one of these, so the callers can use errors.Is instead of the driver's errors.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// wrapErr classifies a database error with the matching sentinel error, the original error is kept in the chain.
+func wrapErr(err error) error {
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) || errors.Is(err, ErrInvalidArgument) {
+		return err
+	}
+
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case !errors.As(err, &pqErr):
+		return err
+	case pqErr.Code.Name() == "unique_violation" || pqErr.Code.Name() == "exclusion_violation":
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case pqErr.Code.Class() == "22" || pqErr.Code.Class() == "23": // data exceptions and the other integrity constraint violations
+		return fmt.Errorf("%w: %w", ErrInvalidArgument, err)
+	}
+
+	return err
+}
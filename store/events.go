@@ -0,0 +1,80 @@
+package store
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/akawula/DoraMatic/events"
+	"github.com/akawula/DoraMatic/github/pullrequests"
+	"github.com/akawula/DoraMatic/github/workflows"
+)
+
+// SetPublisher sends the events of the saved entities to the publisher, they're dropped until it's set.
+func (p *Postgres) SetPublisher(publisher events.Publisher) {
+	p.publisher = publisher
+}
+
+// publish runs after the commit, so a broker that's down doesn't fail the sync; the events are lost though, the next
+// sync only fetches the pull requests updated since, it doesn't publish the saved ones again.
+func (p *Postgres) publish(evs []events.Event) {
+	if len(evs) == 0 {
+		return
+	}
+	if err := p.publisher.Publish(evs); err != nil {
+		p.Logger.Error("can't publish the events", "count", len(evs), "error", err)
+	}
+}
+
+func prEvents(pr pullrequests.PullRequest, at time.Time) []events.Event {
+	evs := []events.Event{{
+		Type: events.PRUpserted,
+		Id:   string(pr.Id),
+		At:   at,
+		Data: map[string]interface{}{
+			"url":              pr.Url,
+			"title":            pr.Title,
+			"state":            pr.State,
+			"author":           normalizeLogin(string(pr.Author.Login)),
+			"repository_owner": pr.Repository.Owner.Login,
+			"repository_name":  pr.Repository.Name,
+			"merged_at":        pr.MergedAt,
+		},
+	}}
+	for _, review := range pr.Reviews.Nodes {
+		evs = append(evs, events.Event{
+			Type: events.ReviewUpserted,
+			Id:   string(review.Id),
+			At:   at,
+			Data: map[string]interface{}{
+				"pr_id":        pr.Id,
+				"author":       normalizeLogin(string(review.Author.Login)),
+				"state":        review.State,
+				"submitted_at": review.SubmittedAt,
+			},
+		})
+	}
+
+	return evs
+}
+
+func runEvents(runs []workflows.Run, at time.Time) []events.Event {
+	evs := []events.Event{}
+	for _, run := range runs {
+		evs = append(evs, events.Event{
+			Type: events.DeploymentRecorded,
+			Id:   strconv.FormatInt(run.Id, 10),
+			At:   at,
+			Data: map[string]interface{}{
+				"url":              run.HtmlUrl,
+				"workflow_name":    run.Name,
+				"repository_owner": run.Repository.Owner.Login,
+				"repository_name":  run.Repository.Name,
+				"head_sha":         run.HeadSha,
+				"status":           run.Status,
+				"failure_category": run.FailureCategory(),
+			},
+		})
+	}
+
+	return evs
+}
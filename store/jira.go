@@ -0,0 +1,93 @@
+package store
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/akawula/DoraMatic/github/pullrequests"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// jiraKey matches issue keys like ABC-123.
+var jiraKey = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-[0-9]+\b`)
+
+type jiraRef struct {
+	PrId   string `db:"pr_id"`
+	Key    string `db:"jira_key"`
+	Source string `db:"source"`
+}
+
+// extractJiraRefs returns the distinct JIRA keys of the pull request with where each was found first.
+func extractJiraRefs(prId string, title string, branch string, body string) []jiraRef {
+	refs := []jiraRef{}
+	seen := map[string]bool{}
+	for _, field := range []struct{ source, text string }{{"title", title}, {"branch", branch}, {"body", body}} {
+		for _, key := range jiraKey.FindAllString(field.text, -1) {
+			if !seen[key] {
+				seen[key] = true
+				refs = append(refs, jiraRef{PrId: prId, Key: key, Source: field.source})
+			}
+		}
+	}
+
+	return refs
+}
+
+// replaceJiraRefs replaces the JIRA references of the pull requests, so keys removed from a title don't linger.
+func replaceJiraRefs(e sqlx.Ext, prIds []string, refs []jiraRef) error {
+	if _, err := e.Exec(`DELETE FROM pr_jira_refs WHERE pr_id = ANY($1)`, pq.Array(prIds)); err != nil {
+		return err
+	}
+
+	for _, vals := range slices.Collect(slices.Chunk(refs, (2<<15-1)/3)) { // chunk the batchUpdate 65k / # of params (3 currently)
+		if _, err := sqlx.NamedExec(e, `INSERT INTO pr_jira_refs (pr_id, jira_key, source) VALUES (:pr_id, :jira_key, :source) ON CONFLICT DO NOTHING`, vals); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func prJiraRefs(pr pullrequests.PullRequest) []jiraRef {
+	return extractJiraRefs(string(pr.Id), string(pr.Title), string(pr.HeadRefName), string(pr.Body))
+}
+
+// BackfillJiraRefs extracts the JIRA references of the pull requests stored before they were extracted at ingest,
+// bodies aren't stored, so only titles and branches are scanned.
+func (p *Postgres) BackfillJiraRefs() (int, error) {
+	var prs []struct {
+		Id     string
+		Title  string
+		Branch string `db:"branch_name"`
+	}
+	err := p.db.Select(&prs, `SELECT id, title, COALESCE(branch_name, '') AS branch_name FROM prs p
+WHERE NOT EXISTS (SELECT 1 FROM pr_jira_refs r WHERE r.pr_id = p.id)`)
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+
+	total := 0
+	for batch := range slices.Chunk(prs, 5000) {
+		ids := []string{}
+		refs := []jiraRef{}
+		for _, pr := range batch {
+			ids = append(ids, pr.Id)
+			refs = append(refs, extractJiraRefs(pr.Id, pr.Title, pr.Branch, "")...)
+		}
+		tx, err := p.db.Beginx()
+		if err != nil {
+			return total, wrapErr(err)
+		}
+		if err = replaceJiraRefs(tx, ids, refs); err != nil {
+			tx.Rollback()
+			return total, wrapErr(err)
+		}
+		if err = tx.Commit(); err != nil {
+			return total, wrapErr(err)
+		}
+		total += len(refs)
+	}
+
+	return total, nil
+}
@@ -0,0 +1,250 @@
+package store
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Migrator struct {
+	db     *sqlx.DB
+	Logger *slog.Logger
+}
+
+func NewMigrator(cfg config.Postgres, logger *slog.Logger) (*Migrator, error) {
+	db, err := sqlx.Connect("postgres", connectionString(cfg, cfg.Host, cfg.Port))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migrator{db: db, Logger: logger}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+
+	return m, err
+}
+
+func (m *Migrator) Close() {
+	m.db.Close()
+}
+
+// Migrations returns the embedded migrations sorted by version, files are named NNNN_name.(up|down).sql.
+func Migrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationsFS, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		name := path.Base(file)
+		prefix, rest, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+
+		content, err := migrationsFS.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := byVersion[version]; !ok {
+			byVersion[version] = &Migration{Version: version}
+		}
+		switch {
+		case strings.HasSuffix(rest, ".up.sql"):
+			byVersion[version].Name = strings.TrimSuffix(rest, ".up.sql")
+			byVersion[version].Up = string(content)
+		case strings.HasSuffix(rest, ".down.sql"):
+			byVersion[version].Down = string(content)
+		default:
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	return migrations, nil
+}
+
+// LatestVersion is the version the code expects the schema to be at.
+func LatestVersion() (int, error) {
+	migrations, err := Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// Version returns the applied schema version, 0 when nothing was applied yet.
+func (m *Migrator) Version() (version int, dirty bool, err error) {
+	rows, err := m.db.Query(`SELECT version, dirty FROM schema_migrations LIMIT 1`)
+	if err != nil {
+		return 0, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&version, &dirty)
+	}
+
+	return version, dirty, err
+}
+
+// Up applies every migration newer than the current version.
+func (m *Migrator) Up() error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		// a failed migration marked its version dirty, its transaction was rolled back so it's applied again
+		m.Logger.Warn("schema is dirty, retrying the failed migration", "version", version)
+		version = previousVersion(migrations, version)
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+		m.Logger.Info("applying migration", "version", migration.Version, "name", migration.Name)
+		if err := m.apply(migration.Up, migration.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Down reverts the last n applied migrations.
+func (m *Migrator) Down(n int) error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("schema is dirty at version %d, fix it manually and use force", version)
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && n > 0; i-- {
+		migration := migrations[i]
+		if migration.Version > version {
+			continue
+		}
+		if len(migration.Down) == 0 {
+			return fmt.Errorf("migration %d has no down script", migration.Version)
+		}
+
+		previous := 0
+		if i > 0 {
+			previous = migrations[i-1].Version
+		}
+		m.Logger.Info("reverting migration", "version", migration.Version, "name", migration.Name)
+		if err := m.apply(migration.Down, previous); err != nil {
+			return err
+		}
+		n--
+	}
+
+	return nil
+}
+
+// Force sets the schema version without running any migration and clears the dirty flag.
+func (m *Migrator) Force(version int) error {
+	return m.setVersion(m.db, version, false)
+}
+
+// Check returns an error when the schema isn't at the version the code expects.
+func (m *Migrator) Check() error {
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if dirty || version != latest {
+		return fmt.Errorf("schema version is %d (dirty: %t), expected %d, run the migrate command", version, dirty, latest)
+	}
+
+	return nil
+}
+
+// apply runs the script and sets the version in a transaction, a failed script is rolled back and leaves the version as it was.
+func (m *Migrator) apply(script string, version int) error {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := m.setVersion(tx, version, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// previousVersion returns the version of the migration before the given one, 0 when it's the first.
+func previousVersion(migrations []Migration, version int) int {
+	previous := 0
+	for _, migration := range migrations {
+		if migration.Version < version {
+			previous = migration.Version
+		}
+	}
+
+	return previous
+}
+
+func (m *Migrator) setVersion(e sqlx.Execer, version int, dirty bool) error {
+	if _, err := e.Exec(`TRUNCATE schema_migrations`); err != nil {
+		return err
+	}
+	if version == 0 && !dirty {
+		return nil
+	}
+
+	_, err := e.Exec(`INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty)
+	return err
+}
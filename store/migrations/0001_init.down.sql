@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS commits;
+DROP TABLE IF EXISTS prs;
+DROP TABLE IF EXISTS repositories;
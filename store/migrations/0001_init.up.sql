@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS repositories (
+    org TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    language TEXT
+);
+
+CREATE TABLE IF NOT EXISTS prs (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    state TEXT NOT NULL,
+    url TEXT NOT NULL,
+    merged_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL,
+    additions INTEGER NOT NULL DEFAULT 0,
+    deletions INTEGER NOT NULL DEFAULT 0,
+    branch_name TEXT,
+    author TEXT NOT NULL,
+    repository_name TEXT NOT NULL,
+    repository_owner TEXT NOT NULL,
+    review_requested_at TIMESTAMPTZ,
+    reviews_requested INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS commits (
+    id TEXT PRIMARY KEY,
+    pr_id TEXT NOT NULL,
+    message TEXT
+);
+
+CREATE TABLE IF NOT EXISTS teams (
+    team TEXT NOT NULL,
+    member TEXT NOT NULL
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS review_requests (
+    pr_id TEXT NOT NULL,
+    reviewer TEXT NOT NULL,
+    reviewer_type TEXT NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (pr_id, reviewer, requested_at)
+);
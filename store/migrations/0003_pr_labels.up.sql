@@ -0,0 +1 @@
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS labels TEXT[] NOT NULL DEFAULT '{}';
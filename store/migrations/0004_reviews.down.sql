@@ -0,0 +1,3 @@
+ALTER TABLE prs DROP COLUMN IF EXISTS first_approved_at;
+
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS reviews (
+    id TEXT PRIMARY KEY,
+    pr_id TEXT NOT NULL,
+    author TEXT NOT NULL,
+    state TEXT NOT NULL,
+    submitted_at TIMESTAMPTZ
+);
+
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS first_approved_at TIMESTAMPTZ;
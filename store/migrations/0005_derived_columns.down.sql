@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS prs_merged_at_lead_times_idx;
+
+ALTER TABLE prs DROP COLUMN IF EXISTS lead_time_to_merge;
+ALTER TABLE prs DROP COLUMN IF EXISTS lead_time_to_review;
+ALTER TABLE prs DROP COLUMN IF EXISTS lead_time_to_code;
+ALTER TABLE prs DROP COLUMN IF EXISTS first_review_at;
+ALTER TABLE prs DROP COLUMN IF EXISTS first_commit_at;
+
+ALTER TABLE commits DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,11 @@
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+
+-- Filled by the cronjob (RecomputeDerivedColumns), durations are in seconds:
+-- code: first commit -> pr created, review: pr created -> first review, merge: first review -> merged.
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS first_commit_at TIMESTAMPTZ;
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS first_review_at TIMESTAMPTZ;
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS lead_time_to_code BIGINT;
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS lead_time_to_review BIGINT;
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS lead_time_to_merge BIGINT;
+
+CREATE INDEX IF NOT EXISTS prs_merged_at_lead_times_idx ON prs (merged_at) INCLUDE (author, lead_time_to_code, lead_time_to_review, lead_time_to_merge);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS repository_aliases;
+
+DROP INDEX IF EXISTS prs_repository_id_idx;
+
+ALTER TABLE prs DROP COLUMN IF EXISTS repository_id;
+ALTER TABLE repositories DROP COLUMN IF EXISTS id;
@@ -0,0 +1,12 @@
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS id TEXT;
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS repository_id TEXT;
+
+CREATE INDEX IF NOT EXISTS prs_repository_id_idx ON prs (repository_id);
+
+-- previous names of renamed/transferred repositories
+CREATE TABLE IF NOT EXISTS repository_aliases (
+    repository_id TEXT NOT NULL,
+    org TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    PRIMARY KEY (org, slug)
+);
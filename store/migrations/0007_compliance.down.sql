@@ -0,0 +1,5 @@
+DROP VIEW IF EXISTS non_compliant_prs;
+DROP VIEW IF EXISTS team_compliance_weekly;
+
+ALTER TABLE prs DROP COLUMN IF EXISTS compliance_failed;
+ALTER TABLE prs DROP COLUMN IF EXISTS compliance_score;
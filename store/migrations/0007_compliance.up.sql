@@ -0,0 +1,17 @@
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS compliance_score REAL;
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS compliance_failed TEXT[];
+
+CREATE OR REPLACE VIEW team_compliance_weekly AS
+SELECT t.team, date_trunc('week', p.created_at) AS week, count(*) AS prs,
+    count(*) FILTER (WHERE p.compliance_score = 1) AS compliant,
+    round(100.0 * count(*) FILTER (WHERE p.compliance_score = 1) / count(*), 2) AS compliance_percentage
+FROM prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.compliance_score IS NOT NULL
+GROUP BY t.team, week;
+
+CREATE OR REPLACE VIEW non_compliant_prs AS
+SELECT t.team, p.id, p.url, p.title, p.author, p.repository_owner, p.repository_name, p.created_at, p.compliance_score, p.compliance_failed
+FROM prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.compliance_score < 1;
@@ -0,0 +1,3 @@
+DROP VIEW IF EXISTS conventional_commits_by_team;
+DROP VIEW IF EXISTS conventional_commits_by_repo;
+DROP VIEW IF EXISTS conventional_commits;
@@ -0,0 +1,34 @@
+-- Conventional Commits (https://www.conventionalcommits.org) type of every stored commit, NULL when the message doesn't follow it
+CREATE OR REPLACE VIEW conventional_commits AS
+SELECT c.id, c.pr_id, c.created_at, p.author, p.repository_owner, p.repository_name,
+    lower(substring(c.message FROM '^([A-Za-z]+)(?:\([^)]*\))?!?: ')) AS type,
+    c.message ~ '^[A-Za-z]+(\([^)]*\))?!: ' OR c.message ~ '(^|\n)BREAKING[ -]CHANGE: ' AS breaking
+FROM commits c
+INNER JOIN prs p ON p.id = c.pr_id;
+
+CREATE OR REPLACE VIEW conventional_commits_by_repo AS
+SELECT repository_owner, repository_name, date_trunc('week', created_at) AS week,
+    count(*) AS commits,
+    count(type) AS conventional,
+    round(100.0 * count(type) / count(*), 2) AS adherence_percentage,
+    count(*) FILTER (WHERE type = 'feat') AS feat,
+    count(*) FILTER (WHERE type = 'fix') AS fix,
+    count(*) FILTER (WHERE type = 'chore') AS chore,
+    count(*) FILTER (WHERE type NOT IN ('feat', 'fix', 'chore')) AS other,
+    count(*) FILTER (WHERE breaking) AS breaking_changes
+FROM conventional_commits
+GROUP BY repository_owner, repository_name, week;
+
+CREATE OR REPLACE VIEW conventional_commits_by_team AS
+SELECT t.team, date_trunc('week', cc.created_at) AS week,
+    count(*) AS commits,
+    count(cc.type) AS conventional,
+    round(100.0 * count(cc.type) / count(*), 2) AS adherence_percentage,
+    count(*) FILTER (WHERE cc.type = 'feat') AS feat,
+    count(*) FILTER (WHERE cc.type = 'fix') AS fix,
+    count(*) FILTER (WHERE cc.type = 'chore') AS chore,
+    count(*) FILTER (WHERE cc.type NOT IN ('feat', 'fix', 'chore')) AS other,
+    count(*) FILTER (WHERE cc.breaking) AS breaking_changes
+FROM conventional_commits cc
+INNER JOIN teams t ON t.member = cc.author
+GROUP BY t.team, week;
@@ -0,0 +1,34 @@
+-- repositories excluded from the metrics while still being synced, slug is a LIKE pattern
+CREATE TABLE IF NOT EXISTS ignored_repositories (
+    org TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    PRIMARY KEY (org, slug)
+);
+
+-- pull requests the metrics are computed from, every metric view should read from it instead of prs.
+-- p.* is expanded when the view is created, recreate it when a metric needs a column added to prs later.
+CREATE OR REPLACE VIEW metric_prs AS
+SELECT p.* FROM prs p
+WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = p.repository_owner AND p.repository_name LIKE i.slug);
+
+CREATE OR REPLACE VIEW team_compliance_weekly AS
+SELECT t.team, date_trunc('week', p.created_at) AS week, count(*) AS prs,
+    count(*) FILTER (WHERE p.compliance_score = 1) AS compliant,
+    round(100.0 * count(*) FILTER (WHERE p.compliance_score = 1) / count(*), 2) AS compliance_percentage
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.compliance_score IS NOT NULL
+GROUP BY t.team, week;
+
+CREATE OR REPLACE VIEW non_compliant_prs AS
+SELECT t.team, p.id, p.url, p.title, p.author, p.repository_owner, p.repository_name, p.created_at, p.compliance_score, p.compliance_failed
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.compliance_score < 1;
+
+CREATE OR REPLACE VIEW conventional_commits AS
+SELECT c.id, c.pr_id, c.created_at, p.author, p.repository_owner, p.repository_name,
+    lower(substring(c.message FROM '^([A-Za-z]+)(?:\([^)]*\))?!?: ')) AS type,
+    c.message ~ '^[A-Za-z]+(\([^)]*\))?!: ' OR c.message ~ '(^|\n)BREAKING[ -]CHANGE: ' AS breaking
+FROM commits c
+INNER JOIN metric_prs p ON p.id = c.pr_id;
@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS team_members;
+DROP TABLE IF EXISTS member_identities;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS member_identities (
+    org TEXT NOT NULL,
+    login TEXT NOT NULL,
+    name_id TEXT,
+    email TEXT,
+    PRIMARY KEY (org, login)
+);
+
+-- team members with their corporate identity, for joining HR/people systems downstream
+CREATE OR REPLACE VIEW team_members AS
+SELECT t.team, t.member, i.org, i.name_id, i.email
+FROM teams t
+LEFT JOIN member_identities i ON i.login = t.member;
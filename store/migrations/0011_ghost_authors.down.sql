@@ -0,0 +1 @@
+-- the backfill is not reversible, real ghost accounts can't be told apart from normalized ones
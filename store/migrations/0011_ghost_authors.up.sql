@@ -0,0 +1,2 @@
+UPDATE prs SET author = 'ghost' WHERE author = '';
+UPDATE reviews SET author = 'ghost' WHERE author = '';
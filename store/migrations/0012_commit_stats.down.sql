@@ -0,0 +1,60 @@
+-- metric_prs expands prs.*, so it and the views reading it are rebuilt without the churn column
+DROP VIEW IF EXISTS team_churn_weekly;
+DROP VIEW IF EXISTS metric_prs CASCADE;
+
+ALTER TABLE prs DROP COLUMN IF EXISTS churn;
+ALTER TABLE commits DROP COLUMN IF EXISTS deletions;
+ALTER TABLE commits DROP COLUMN IF EXISTS additions;
+
+CREATE VIEW metric_prs AS
+SELECT p.* FROM prs p
+WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = p.repository_owner AND p.repository_name LIKE i.slug);
+
+CREATE VIEW team_compliance_weekly AS
+SELECT t.team, date_trunc('week', p.created_at) AS week, count(*) AS prs,
+    count(*) FILTER (WHERE p.compliance_score = 1) AS compliant,
+    round(100.0 * count(*) FILTER (WHERE p.compliance_score = 1) / count(*), 2) AS compliance_percentage
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.compliance_score IS NOT NULL
+GROUP BY t.team, week;
+
+CREATE VIEW non_compliant_prs AS
+SELECT t.team, p.id, p.url, p.title, p.author, p.repository_owner, p.repository_name, p.created_at, p.compliance_score, p.compliance_failed
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.compliance_score < 1;
+
+CREATE VIEW conventional_commits AS
+SELECT c.id, c.pr_id, c.created_at, p.author, p.repository_owner, p.repository_name,
+    lower(substring(c.message FROM '^([A-Za-z]+)(?:\([^)]*\))?!?: ')) AS type,
+    c.message ~ '^[A-Za-z]+(\([^)]*\))?!: ' OR c.message ~ '(^|\n)BREAKING[ -]CHANGE: ' AS breaking
+FROM commits c
+INNER JOIN metric_prs p ON p.id = c.pr_id;
+
+CREATE VIEW conventional_commits_by_repo AS
+SELECT repository_owner, repository_name, date_trunc('week', created_at) AS week,
+    count(*) AS commits,
+    count(type) AS conventional,
+    round(100.0 * count(type) / count(*), 2) AS adherence_percentage,
+    count(*) FILTER (WHERE type = 'feat') AS feat,
+    count(*) FILTER (WHERE type = 'fix') AS fix,
+    count(*) FILTER (WHERE type = 'chore') AS chore,
+    count(*) FILTER (WHERE type NOT IN ('feat', 'fix', 'chore')) AS other,
+    count(*) FILTER (WHERE breaking) AS breaking_changes
+FROM conventional_commits
+GROUP BY repository_owner, repository_name, week;
+
+CREATE VIEW conventional_commits_by_team AS
+SELECT t.team, date_trunc('week', cc.created_at) AS week,
+    count(*) AS commits,
+    count(cc.type) AS conventional,
+    round(100.0 * count(cc.type) / count(*), 2) AS adherence_percentage,
+    count(*) FILTER (WHERE cc.type = 'feat') AS feat,
+    count(*) FILTER (WHERE cc.type = 'fix') AS fix,
+    count(*) FILTER (WHERE cc.type = 'chore') AS chore,
+    count(*) FILTER (WHERE cc.type NOT IN ('feat', 'fix', 'chore')) AS other,
+    count(*) FILTER (WHERE cc.breaking) AS breaking_changes
+FROM conventional_commits cc
+INNER JOIN teams t ON t.member = cc.author
+GROUP BY t.team, week;
@@ -0,0 +1,19 @@
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS additions INTEGER;
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS deletions INTEGER;
+
+-- lines changed by the commits on top of the final diff, filled by the cronjob (RecomputeDerivedColumns)
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS churn INTEGER;
+
+CREATE OR REPLACE VIEW metric_prs AS
+SELECT p.* FROM prs p
+WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = p.repository_owner AND p.repository_name LIKE i.slug);
+
+CREATE OR REPLACE VIEW team_churn_weekly AS
+SELECT t.team, date_trunc('week', p.merged_at) AS week, count(*) AS prs,
+    sum(p.additions + p.deletions) AS lines_changed,
+    sum(p.churn) AS churn,
+    round(100.0 * sum(p.churn) / nullif(sum(p.additions + p.deletions + p.churn), 0), 2) AS churn_percentage
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.state = 'MERGED' AND p.churn IS NOT NULL
+GROUP BY t.team, week;
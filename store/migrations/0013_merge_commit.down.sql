@@ -0,0 +1,2 @@
+-- merge_commit_sha stays on prs, metric_prs expands it since 0015 and can't drop it
+DROP INDEX IF EXISTS prs_merge_commit_sha_idx;
@@ -0,0 +1,4 @@
+-- canonical sha of a merged pull request, squash and rebase merges create commits that aren't part of the pull request
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS merge_commit_sha TEXT;
+
+CREATE INDEX IF NOT EXISTS prs_merge_commit_sha_idx ON prs (merge_commit_sha);
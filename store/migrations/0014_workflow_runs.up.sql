@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS workflow_runs (
+    id BIGINT PRIMARY KEY,
+    repository_owner TEXT NOT NULL,
+    repository_name TEXT NOT NULL,
+    workflow_name TEXT NOT NULL,
+    head_branch TEXT,
+    head_sha TEXT NOT NULL,
+    event TEXT,
+    status TEXT NOT NULL,
+    conclusion TEXT,
+    url TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    run_started_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS workflow_runs_repository_created_at_idx ON workflow_runs (repository_owner, repository_name, created_at);
+CREATE INDEX IF NOT EXISTS workflow_runs_head_sha_idx ON workflow_runs (head_sha);
@@ -0,0 +1,5 @@
+-- metric_prs keeps the columns expanded by the up migration, CREATE OR REPLACE VIEW can't drop them
+DROP VIEW IF EXISTS team_change_failure_rate_weekly;
+DROP VIEW IF EXISTS repo_change_failure_rate_weekly;
+
+ALTER TABLE workflow_runs DROP COLUMN IF EXISTS failure_category;
@@ -0,0 +1,68 @@
+ALTER TABLE workflow_runs ADD COLUMN IF NOT EXISTS failure_category TEXT;
+
+UPDATE workflow_runs SET failure_category = CASE
+    WHEN conclusion = 'success' THEN 'success'
+    WHEN conclusion IN ('failure', 'timed_out') THEN 'failure'
+    WHEN conclusion = 'startup_failure' THEN 'infrastructure'
+    WHEN conclusion IS NULL THEN NULL
+    ELSE 'cancelled'
+END;
+
+-- recreated so it expands the prs columns added since 0009, merge_commit_sha among them
+CREATE OR REPLACE VIEW metric_prs AS
+SELECT p.* FROM prs p
+WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = p.repository_owner AND p.repository_name LIKE i.slug);
+
+-- change failure rate from deploy workflow runs next to the pull request heuristic (reverts and hotfixes among merged pull requests)
+CREATE OR REPLACE VIEW repo_change_failure_rate_weekly AS
+WITH deploys AS (
+    SELECT repository_owner, repository_name, date_trunc('week', created_at) AS week,
+        count(*) FILTER (WHERE failure_category IN ('success', 'failure')) AS deploys,
+        count(*) FILTER (WHERE failure_category = 'failure') AS failed_deploys
+    FROM workflow_runs w
+    WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = w.repository_owner AND w.repository_name LIKE i.slug)
+    GROUP BY repository_owner, repository_name, week
+), merges AS (
+    SELECT repository_owner, repository_name, date_trunc('week', merged_at) AS week,
+        count(*) AS merged_prs,
+        count(*) FILTER (WHERE title ILIKE 'revert%' OR branch_name ILIKE 'hotfix%') AS failure_prs
+    FROM metric_prs
+    WHERE state = 'MERGED'
+    GROUP BY repository_owner, repository_name, week
+)
+SELECT COALESCE(d.repository_owner, m.repository_owner) AS repository_owner,
+    COALESCE(d.repository_name, m.repository_name) AS repository_name,
+    COALESCE(d.week, m.week) AS week,
+    d.deploys, d.failed_deploys,
+    round(100.0 * d.failed_deploys / nullif(d.deploys, 0), 2) AS workflow_cfr_percentage,
+    m.merged_prs, m.failure_prs,
+    round(100.0 * m.failure_prs / nullif(m.merged_prs, 0), 2) AS pr_cfr_percentage
+FROM deploys d
+FULL OUTER JOIN merges m ON m.repository_owner = d.repository_owner AND m.repository_name = d.repository_name AND m.week = d.week;
+
+-- deploy runs are attributed to the teams of the author of the pull request whose merge commit they deployed
+CREATE OR REPLACE VIEW team_change_failure_rate_weekly AS
+WITH deploys AS (
+    SELECT t.team, date_trunc('week', w.created_at) AS week,
+        count(*) FILTER (WHERE w.failure_category IN ('success', 'failure')) AS deploys,
+        count(*) FILTER (WHERE w.failure_category = 'failure') AS failed_deploys
+    FROM workflow_runs w
+    INNER JOIN metric_prs p ON p.merge_commit_sha = w.head_sha
+    INNER JOIN teams t ON t.member = p.author
+    GROUP BY t.team, week
+), merges AS (
+    SELECT t.team, date_trunc('week', p.merged_at) AS week,
+        count(*) AS merged_prs,
+        count(*) FILTER (WHERE p.title ILIKE 'revert%' OR p.branch_name ILIKE 'hotfix%') AS failure_prs
+    FROM metric_prs p
+    INNER JOIN teams t ON t.member = p.author
+    WHERE p.state = 'MERGED'
+    GROUP BY t.team, week
+)
+SELECT COALESCE(d.team, m.team) AS team, COALESCE(d.week, m.week) AS week,
+    d.deploys, d.failed_deploys,
+    round(100.0 * d.failed_deploys / nullif(d.deploys, 0), 2) AS workflow_cfr_percentage,
+    m.merged_prs, m.failure_prs,
+    round(100.0 * m.failure_prs / nullif(m.merged_prs, 0), 2) AS pr_cfr_percentage
+FROM deploys d
+FULL OUTER JOIN merges m ON m.team = d.team AND m.week = d.week;
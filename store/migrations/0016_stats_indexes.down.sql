@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS teams_team_member_idx;
+DROP INDEX IF EXISTS reviews_pr_id_submitted_at_idx;
+DROP INDEX IF EXISTS commits_pr_id_created_at_idx;
+DROP INDEX IF EXISTS prs_merged_at_state_idx;
+DROP INDEX IF EXISTS prs_created_at_author_idx;
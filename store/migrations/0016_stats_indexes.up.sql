@@ -0,0 +1,15 @@
+-- composite indexes for the predicates the metric views filter and join on.
+-- the migration runs in a transaction, so the indexes are built without CONCURRENTLY and block writes to the tables
+-- while they're built; on installations with millions of pull requests create them by hand with CREATE INDEX CONCURRENTLY
+-- (same names) before migrating, IF NOT EXISTS skips them here.
+CREATE INDEX IF NOT EXISTS prs_created_at_author_idx ON prs (created_at, author);
+CREATE INDEX IF NOT EXISTS prs_merged_at_state_idx ON prs (merged_at, state);
+CREATE INDEX IF NOT EXISTS commits_pr_id_created_at_idx ON commits (pr_id, created_at);
+CREATE INDEX IF NOT EXISTS reviews_pr_id_submitted_at_idx ON reviews (pr_id, submitted_at);
+CREATE INDEX IF NOT EXISTS teams_team_member_idx ON teams (team, member);
+
+-- refresh the planner statistics so the new indexes are picked up right away, after large imports run ANALYZE again
+ANALYZE prs;
+ANALYZE commits;
+ANALYZE reviews;
+ANALYZE teams;
@@ -0,0 +1 @@
+-- closed_at stays on prs, metric_prs expands it since 0020 and can't drop it
@@ -0,0 +1,2 @@
+-- set for the pull requests closed without merging too, those are synced when GITHUB_PR_STATES includes CLOSED
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ;
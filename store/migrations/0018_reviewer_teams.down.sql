@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS team_cross_team_reviews_weekly;
+DROP VIEW IF EXISTS review_teams;
@@ -0,0 +1,24 @@
+-- reviews of other people's pull requests with the reviewer's teams, a review is cross-team when the reviewer
+-- doesn't share any team with the pull request author; teams are joined rather than stored, so the attribution
+-- follows the current team membership
+CREATE OR REPLACE VIEW review_teams AS
+SELECT r.id, r.pr_id, r.author AS reviewer, r.state, r.submitted_at, p.author, t.team AS reviewer_team,
+    NOT EXISTS (
+        SELECT 1 FROM teams rt INNER JOIN teams pt ON pt.team = rt.team
+        WHERE rt.member = r.author AND pt.member = p.author
+    ) AS cross_team
+FROM reviews r
+INNER JOIN metric_prs p ON p.id = r.pr_id
+LEFT JOIN teams t ON t.member = r.author
+WHERE r.author <> p.author AND r.state <> 'PENDING';
+
+-- share of the reviews on each team's pull requests that came from outside the team
+CREATE OR REPLACE VIEW team_cross_team_reviews_weekly AS
+SELECT t.team, date_trunc('week', rt.submitted_at) AS week,
+    count(DISTINCT rt.id) AS reviews,
+    count(DISTINCT rt.id) FILTER (WHERE NOT EXISTS (SELECT 1 FROM teams m WHERE m.team = t.team AND m.member = rt.reviewer)) AS outside_reviews,
+    round(100.0 * count(DISTINCT rt.id) FILTER (WHERE NOT EXISTS (SELECT 1 FROM teams m WHERE m.team = t.team AND m.member = rt.reviewer)) / count(DISTINCT rt.id), 2) AS outside_percentage
+FROM review_teams rt
+INNER JOIN teams t ON t.member = rt.author
+WHERE rt.submitted_at IS NOT NULL
+GROUP BY t.team, week;
@@ -0,0 +1,9 @@
+-- JIRA keys referenced by the pull request title, branch or body, extracted when the pull request is saved
+CREATE TABLE IF NOT EXISTS pr_jira_refs (
+    pr_id TEXT NOT NULL,
+    jira_key TEXT NOT NULL,
+    source TEXT NOT NULL,
+    PRIMARY KEY (pr_id, jira_key)
+);
+
+CREATE INDEX IF NOT EXISTS pr_jira_refs_jira_key_idx ON pr_jira_refs (jira_key);
@@ -0,0 +1,4 @@
+-- metric_prs keeps the columns expanded by the up migration, CREATE OR REPLACE VIEW can't drop them
+DROP FUNCTION IF EXISTS wip_over_limit(INTEGER);
+DROP VIEW IF EXISTS team_wip_daily;
+DROP VIEW IF EXISTS member_wip_daily;
@@ -0,0 +1,33 @@
+-- recreated so it expands closed_at
+CREATE OR REPLACE VIEW metric_prs AS
+SELECT p.* FROM prs p
+WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = p.repository_owner AND p.repository_name LIKE i.slug);
+
+-- open pull requests per author and day over the last year, a pull request is in progress from its creation until
+-- it's merged or closed
+CREATE OR REPLACE VIEW member_wip_daily AS
+SELECT d.day, p.author, count(*) AS wip
+FROM generate_series(date_trunc('day', current_timestamp) - interval '365 days', date_trunc('day', current_timestamp), interval '1 day') AS d(day)
+INNER JOIN metric_prs p ON p.created_at < d.day + interval '1 day'
+    AND COALESCE(p.merged_at, p.closed_at, 'infinity') >= d.day
+WHERE (p.state <> 'CLOSED' OR p.closed_at IS NOT NULL) -- closed before closed_at was stored, the end isn't known
+GROUP BY d.day, p.author;
+
+CREATE OR REPLACE VIEW team_wip_daily AS
+SELECT w.day, t.team, sum(w.wip) AS wip, count(*) AS members_with_wip,
+    round(sum(w.wip)::numeric / (SELECT count(*) FROM teams m WHERE m.team = t.team), 2) AS average_wip,
+    max(w.wip) AS max_member_wip
+FROM member_wip_daily w
+INNER JOIN teams t ON t.member = w.author
+GROUP BY w.day, t.team;
+
+-- days when members of a team had more pull requests in progress than the limit, e.g. SELECT * FROM wip_over_limit(3)
+CREATE OR REPLACE FUNCTION wip_over_limit(wip_limit INTEGER)
+RETURNS TABLE (day TIMESTAMPTZ, team TEXT, author TEXT, wip BIGINT)
+LANGUAGE sql STABLE AS $$
+    SELECT w.day, t.team, w.author, w.wip
+    FROM member_wip_daily w
+    INNER JOIN teams t ON t.member = w.author
+    WHERE w.wip > wip_limit
+    ORDER BY w.day, t.team, w.author
+$$;
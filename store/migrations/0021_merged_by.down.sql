@@ -0,0 +1,3 @@
+-- merged_by and merge_method stay on prs, metric_prs expands them and CREATE OR REPLACE VIEW can't drop them
+DROP VIEW IF EXISTS merge_method_reverts;
+DROP VIEW IF EXISTS team_merges_weekly;
@@ -0,0 +1,34 @@
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS merged_by TEXT;
+-- merge, squash or rebase, guessed from the merge commit as GitHub doesn't expose it
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS merge_method TEXT;
+
+-- recreated so it expands merged_by and merge_method
+CREATE OR REPLACE VIEW metric_prs AS
+SELECT p.* FROM prs p
+WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = p.repository_owner AND p.repository_name LIKE i.slug);
+
+-- who merges the team's pull requests
+CREATE OR REPLACE VIEW team_merges_weekly AS
+SELECT t.team, date_trunc('week', p.merged_at) AS week, count(*) AS merged,
+    count(*) FILTER (WHERE p.merged_by = p.author) AS self_merged,
+    round(100.0 * count(*) FILTER (WHERE p.merged_by = p.author) / count(*), 2) AS self_merge_percentage,
+    count(*) FILTER (WHERE p.merge_method = 'merge') AS merge_commits,
+    count(*) FILTER (WHERE p.merge_method = 'squash') AS squashed,
+    count(*) FILTER (WHERE p.merge_method = 'rebase') AS rebased
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.state = 'MERGED' AND p.merged_by IS NOT NULL
+GROUP BY t.team, week;
+
+-- share of the merged pull requests that were reverted later (by a GitHub "Revert" pull request) per merge method
+CREATE OR REPLACE VIEW merge_method_reverts AS
+SELECT p.repository_owner, p.repository_name, date_trunc('month', p.merged_at) AS month, p.merge_method,
+    count(*) AS merged,
+    count(*) FILTER (WHERE EXISTS (
+        SELECT 1 FROM metric_prs r
+        WHERE r.repository_owner = p.repository_owner AND r.repository_name = p.repository_name
+        AND r.title = 'Revert "' || p.title || '"' AND r.created_at > p.merged_at
+    )) AS reverted
+FROM metric_prs p
+WHERE p.state = 'MERGED' AND p.merge_method IS NOT NULL
+GROUP BY p.repository_owner, p.repository_name, month, p.merge_method;
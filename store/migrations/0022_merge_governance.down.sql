@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS team_merge_governance_weekly;
+DROP VIEW IF EXISTS merge_governance_violations;
@@ -0,0 +1 @@
+-- changed_files stays on prs, metric_prs expands it since 0024 and can't drop it
@@ -0,0 +1,2 @@
+-- NULL for the pull requests synced before it was fetched
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS changed_files INTEGER;
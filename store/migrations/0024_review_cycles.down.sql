@@ -0,0 +1,3 @@
+-- review_cycles stays on prs, metric_prs expands it and CREATE OR REPLACE VIEW can't drop it
+DROP FUNCTION IF EXISTS excessive_review_cycles(INTEGER);
+DROP VIEW IF EXISTS team_review_cycles_weekly;
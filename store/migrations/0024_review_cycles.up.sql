@@ -0,0 +1,27 @@
+-- changes requested reviews that were addressed with new commits and reviewed again, filled by the cronjob (RecomputeDerivedColumns)
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS review_cycles INTEGER;
+
+-- recreated so it expands review_cycles
+CREATE OR REPLACE VIEW metric_prs AS
+SELECT p.* FROM prs p
+WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = p.repository_owner AND p.repository_name LIKE i.slug);
+
+CREATE OR REPLACE VIEW team_review_cycles_weekly AS
+SELECT t.team, date_trunc('week', p.merged_at) AS week, count(*) AS prs,
+    round(avg(p.review_cycles), 2) AS average_review_cycles,
+    max(p.review_cycles) AS max_review_cycles
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.state = 'MERGED' AND p.review_cycles IS NOT NULL
+GROUP BY t.team, week;
+
+-- pull requests that went through more review cycles than the limit, e.g. SELECT * FROM excessive_review_cycles(3)
+CREATE OR REPLACE FUNCTION excessive_review_cycles(max_cycles INTEGER)
+RETURNS TABLE (team TEXT, id TEXT, url TEXT, title TEXT, author TEXT, repository_owner TEXT, repository_name TEXT, created_at TIMESTAMPTZ, review_cycles INTEGER)
+LANGUAGE sql STABLE AS $$
+    SELECT t.team, p.id, p.url, p.title, p.author, p.repository_owner, p.repository_name, p.created_at, p.review_cycles
+    FROM metric_prs p
+    LEFT JOIN teams t ON t.member = p.author
+    WHERE p.review_cycles > max_cycles
+    ORDER BY p.review_cycles DESC, p.created_at DESC
+$$;
@@ -0,0 +1 @@
+DROP VIEW IF EXISTS team_lead_time_histogram;
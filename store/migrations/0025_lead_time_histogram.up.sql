@@ -0,0 +1,12 @@
+-- distribution of the lead time segments of merged pull requests per team and month,
+-- buckets: <1h, 1-4h, 4-24h, 1-3d, >3d
+CREATE OR REPLACE VIEW team_lead_time_histogram AS
+SELECT t.team, date_trunc('month', p.merged_at) AS month, l.metric,
+    width_bucket(l.seconds, ARRAY[3600, 14400, 86400, 259200]) AS bucket,
+    (ARRAY['<1h', '1-4h', '4-24h', '1-3d', '>3d'])[width_bucket(l.seconds, ARRAY[3600, 14400, 86400, 259200]) + 1] AS bucket_label,
+    count(*) AS prs
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+CROSS JOIN LATERAL (VALUES ('code', p.lead_time_to_code), ('review', p.lead_time_to_review), ('merge', p.lead_time_to_merge)) AS l(metric, seconds)
+WHERE p.state = 'MERGED' AND l.seconds IS NOT NULL
+GROUP BY t.team, month, l.metric, bucket, bucket_label;
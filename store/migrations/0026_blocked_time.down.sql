@@ -0,0 +1,5 @@
+-- blocked_seconds stays on prs, metric_prs expands it and CREATE OR REPLACE VIEW can't drop it
+DROP VIEW IF EXISTS team_blocked_time_weekly;
+
+DROP TABLE IF EXISTS blocking_labels;
+DROP TABLE IF EXISTS pr_label_events;
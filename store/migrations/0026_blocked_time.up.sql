@@ -0,0 +1,35 @@
+-- labels added to and removed from the pull requests, only the last 25 events of each pull request are fetched
+CREATE TABLE IF NOT EXISTS pr_label_events (
+    pr_id TEXT NOT NULL,
+    label TEXT NOT NULL,
+    action TEXT NOT NULL, -- LABELED or UNLABELED
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (pr_id, label, action, created_at)
+);
+
+-- labels that put a pull request on hold, lower cased, replaced by the cronjob from BLOCKING_LABELS
+CREATE TABLE IF NOT EXISTS blocking_labels (
+    label TEXT PRIMARY KEY
+);
+
+-- seconds the pull request spent with any blocking label until it was merged or closed (or until now when it's open),
+-- overlapping labels are counted once; filled by the cronjob (RecomputeDerivedColumns)
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS blocked_seconds BIGINT;
+
+-- recreated so it expands blocked_seconds
+CREATE OR REPLACE VIEW metric_prs AS
+SELECT p.* FROM prs p
+WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = p.repository_owner AND p.repository_name LIKE i.slug);
+
+-- the lead time is from the creation to the merge, the unblocked one doesn't count the blocked time
+CREATE OR REPLACE VIEW team_blocked_time_weekly AS
+SELECT t.team, date_trunc('week', p.merged_at) AS week, count(*) AS prs,
+    count(*) FILTER (WHERE p.blocked_seconds > 0) AS blocked_prs,
+    round(avg(p.blocked_seconds)) AS average_blocked_seconds,
+    round(avg(p.blocked_seconds) FILTER (WHERE p.blocked_seconds > 0)) AS average_blocked_seconds_when_blocked,
+    round(avg(EXTRACT(EPOCH FROM p.merged_at - p.created_at))) AS average_lead_time,
+    round(avg(GREATEST(EXTRACT(EPOCH FROM p.merged_at - p.created_at) - COALESCE(p.blocked_seconds, 0), 0))) AS average_unblocked_lead_time
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.state = 'MERGED'
+GROUP BY t.team, week;
@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS team_review_latency_heatmap(TEXT, TIMESTAMPTZ, TIMESTAMPTZ, TEXT);
@@ -0,0 +1,30 @@
+-- 7x24 heatmap of a team's pull requests created and of how long the first review took after it was requested,
+-- by day of week (0 is Sunday) and hour in the given time zone,
+-- e.g. SELECT * FROM team_review_latency_heatmap('Webstack', now() - interval '90 days', now(), 'Europe/Warsaw')
+CREATE OR REPLACE FUNCTION team_review_latency_heatmap(team_name TEXT, from_at TIMESTAMPTZ, to_at TIMESTAMPTZ, tz TEXT DEFAULT 'UTC')
+RETURNS TABLE (dow INTEGER, hour INTEGER, prs_created BIGINT, reviews BIGINT, average_review_latency NUMERIC, median_review_latency DOUBLE PRECISION)
+LANGUAGE sql STABLE AS $$
+    WITH team_prs AS (
+        SELECT p.created_at, COALESCE(p.review_requested_at, p.created_at) AS requested_at, p.first_review_at
+        FROM metric_prs p
+        INNER JOIN teams t ON t.member = p.author
+        WHERE t.team = team_name AND p.created_at >= from_at AND p.created_at < to_at
+    ), created AS (
+        SELECT EXTRACT(DOW FROM created_at AT TIME ZONE tz)::INTEGER AS dow, EXTRACT(HOUR FROM created_at AT TIME ZONE tz)::INTEGER AS hour, count(*) AS prs
+        FROM team_prs
+        GROUP BY 1, 2
+    ), reviewed AS (
+        SELECT EXTRACT(DOW FROM requested_at AT TIME ZONE tz)::INTEGER AS dow, EXTRACT(HOUR FROM requested_at AT TIME ZONE tz)::INTEGER AS hour, count(*) AS reviews,
+            round(avg(EXTRACT(EPOCH FROM first_review_at - requested_at))) AS average_latency,
+            percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM first_review_at - requested_at)) AS median_latency
+        FROM team_prs
+        WHERE first_review_at >= requested_at
+        GROUP BY 1, 2
+    )
+    SELECT d.dow, h.hour, COALESCE(c.prs, 0), COALESCE(r.reviews, 0), r.average_latency, r.median_latency
+    FROM generate_series(0, 6) AS d(dow)
+    CROSS JOIN generate_series(0, 23) AS h(hour)
+    LEFT JOIN created c ON c.dow = d.dow AND c.hour = h.hour
+    LEFT JOIN reviewed r ON r.dow = d.dow AND r.hour = h.hour
+    ORDER BY d.dow, h.hour
+$$;
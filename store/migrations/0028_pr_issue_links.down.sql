@@ -0,0 +1,4 @@
+DROP VIEW IF EXISTS team_issue_lead_time_weekly;
+DROP VIEW IF EXISTS team_issue_links_weekly;
+
+DROP TABLE IF EXISTS pr_issue_links;
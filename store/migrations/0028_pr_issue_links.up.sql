@@ -0,0 +1,36 @@
+-- GitHub issues the pull requests close when they're merged, replaced when the pull request is saved
+CREATE TABLE IF NOT EXISTS pr_issue_links (
+    pr_id TEXT NOT NULL,
+    issue_url TEXT NOT NULL,
+    repository TEXT NOT NULL, -- owner/name of the issue, it may be another repository than the pull request's
+    number INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ,
+    PRIMARY KEY (pr_id, issue_url)
+);
+
+CREATE INDEX IF NOT EXISTS pr_issue_links_issue_url_idx ON pr_issue_links (issue_url);
+
+CREATE OR REPLACE VIEW team_issue_links_weekly AS
+SELECT t.team, date_trunc('week', p.merged_at) AS week, count(*) AS prs,
+    count(*) FILTER (WHERE EXISTS (SELECT 1 FROM pr_issue_links l WHERE l.pr_id = p.id)) AS linked_prs,
+    round(100.0 * count(*) FILTER (WHERE EXISTS (SELECT 1 FROM pr_issue_links l WHERE l.pr_id = p.id)) / count(*), 2) AS linked_percentage
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+WHERE p.state = 'MERGED'
+GROUP BY t.team, week;
+
+-- time from an issue being opened to being closed, counted for the team of the author of the pull request that closed it,
+-- an issue closed by several pull requests of the team is counted once
+CREATE OR REPLACE VIEW team_issue_lead_time_weekly AS
+SELECT i.team, date_trunc('week', i.closed_at) AS week, count(*) AS issues,
+    round(avg(EXTRACT(EPOCH FROM i.closed_at - i.created_at))) AS average_lead_time,
+    percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM i.closed_at - i.created_at)) AS median_lead_time
+FROM (
+    SELECT DISTINCT t.team, l.issue_url, l.created_at, l.closed_at
+    FROM pr_issue_links l
+    INNER JOIN metric_prs p ON p.id = l.pr_id
+    INNER JOIN teams t ON t.member = p.author
+    WHERE p.state = 'MERGED' AND l.closed_at IS NOT NULL
+) i
+GROUP BY i.team, week;
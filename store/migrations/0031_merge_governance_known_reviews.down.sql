@@ -0,0 +1,26 @@
+-- the checked column added by the up migration can't be dropped with CREATE OR REPLACE VIEW
+DROP VIEW IF EXISTS team_merge_governance_weekly;
+
+CREATE OR REPLACE VIEW merge_governance_violations AS
+SELECT t.team, p.id, p.url, p.title, p.author, p.merged_by, p.repository_owner, p.repository_name, p.merged_at,
+    NOT EXISTS (SELECT 1 FROM reviews r WHERE r.pr_id = p.id AND r.state = 'APPROVED' AND r.author <> p.author) AS unapproved,
+    p.merged_by IS NOT NULL AND p.merged_by = p.author AS self_merged
+FROM metric_prs p
+LEFT JOIN teams t ON t.member = p.author
+WHERE p.state = 'MERGED'
+AND (
+    NOT EXISTS (SELECT 1 FROM reviews r WHERE r.pr_id = p.id AND r.state = 'APPROVED' AND r.author <> p.author)
+    OR p.merged_by = p.author
+);
+
+CREATE OR REPLACE VIEW team_merge_governance_weekly AS
+SELECT t.team, date_trunc('week', p.merged_at) AS week, count(*) AS merged,
+    count(v.id) FILTER (WHERE v.unapproved) AS unapproved,
+    round(100.0 * count(v.id) FILTER (WHERE v.unapproved) / count(*), 2) AS unapproved_percentage,
+    count(v.id) FILTER (WHERE v.self_merged) AS self_merged,
+    round(100.0 * count(v.id) FILTER (WHERE v.self_merged) / count(*), 2) AS self_merge_percentage
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+LEFT JOIN merge_governance_violations v ON v.id = p.id AND v.team = t.team
+WHERE p.state = 'MERGED'
+GROUP BY t.team, week;
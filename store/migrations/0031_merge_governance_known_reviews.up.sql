@@ -0,0 +1,27 @@
+-- pull requests merged before the reviews and merged_by were synced have neither, they would all read as unapproved;
+-- merged_by is set since then for every merge, so only the pull requests with it are checked
+CREATE OR REPLACE VIEW merge_governance_violations AS
+SELECT t.team, p.id, p.url, p.title, p.author, p.merged_by, p.repository_owner, p.repository_name, p.merged_at,
+    NOT EXISTS (SELECT 1 FROM reviews r WHERE r.pr_id = p.id AND r.state = 'APPROVED' AND r.author <> p.author) AS unapproved,
+    p.merged_by = p.author AS self_merged
+FROM metric_prs p
+LEFT JOIN teams t ON t.member = p.author
+WHERE p.state = 'MERGED' AND p.merged_by IS NOT NULL
+AND (
+    NOT EXISTS (SELECT 1 FROM reviews r WHERE r.pr_id = p.id AND r.state = 'APPROVED' AND r.author <> p.author)
+    OR p.merged_by = p.author
+);
+
+-- the percentages are of the checked pull requests, merged counts all of them
+CREATE OR REPLACE VIEW team_merge_governance_weekly AS
+SELECT t.team, date_trunc('week', p.merged_at) AS week, count(*) AS merged,
+    count(v.id) FILTER (WHERE v.unapproved) AS unapproved,
+    round(100.0 * count(v.id) FILTER (WHERE v.unapproved) / nullif(count(*) FILTER (WHERE p.merged_by IS NOT NULL), 0), 2) AS unapproved_percentage,
+    count(v.id) FILTER (WHERE v.self_merged) AS self_merged,
+    round(100.0 * count(v.id) FILTER (WHERE v.self_merged) / nullif(count(*) FILTER (WHERE p.merged_by IS NOT NULL), 0), 2) AS self_merge_percentage,
+    count(*) FILTER (WHERE p.merged_by IS NOT NULL) AS checked
+FROM metric_prs p
+INNER JOIN teams t ON t.member = p.author
+LEFT JOIN merge_governance_violations v ON v.id = p.id AND v.team = t.team
+WHERE p.state = 'MERGED'
+GROUP BY t.team, week;
@@ -0,0 +1 @@
+-- the cleared guesses aren't restored, the sync stores the method again when the pull requests are fetched
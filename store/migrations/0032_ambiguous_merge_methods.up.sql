@@ -0,0 +1,4 @@
+-- a squashed single commit can't be told from a rebased one, the guesses stored for them are unknown now
+UPDATE prs p SET merge_method = NULL
+WHERE p.merge_method IN ('rebase', 'squash')
+AND (SELECT count(*) FROM commits c WHERE c.pr_id = p.id) < 2;
@@ -0,0 +1,82 @@
+-- the views had no repository_id column, CREATE OR REPLACE VIEW can't drop it
+DROP VIEW IF EXISTS merge_method_reverts;
+DROP VIEW IF EXISTS repo_change_failure_rate_weekly;
+DROP VIEW IF EXISTS conventional_commits_by_team;
+DROP VIEW IF EXISTS conventional_commits_by_repo;
+DROP VIEW IF EXISTS conventional_commits;
+
+CREATE OR REPLACE VIEW conventional_commits AS
+SELECT c.id, c.pr_id, c.created_at, p.author, p.repository_owner, p.repository_name,
+    lower(substring(c.message FROM '^([A-Za-z]+)(?:\([^)]*\))?!?: ')) AS type,
+    c.message ~ '^[A-Za-z]+(\([^)]*\))?!: ' OR c.message ~ '(^|\n)BREAKING[ -]CHANGE: ' AS breaking
+FROM commits c
+INNER JOIN metric_prs p ON p.id = c.pr_id;
+
+CREATE OR REPLACE VIEW conventional_commits_by_repo AS
+SELECT repository_owner, repository_name, date_trunc('week', created_at) AS week,
+    count(*) AS commits,
+    count(type) AS conventional,
+    round(100.0 * count(type) / count(*), 2) AS adherence_percentage,
+    count(*) FILTER (WHERE type = 'feat') AS feat,
+    count(*) FILTER (WHERE type = 'fix') AS fix,
+    count(*) FILTER (WHERE type = 'chore') AS chore,
+    count(*) FILTER (WHERE type NOT IN ('feat', 'fix', 'chore')) AS other,
+    count(*) FILTER (WHERE breaking) AS breaking_changes
+FROM conventional_commits
+GROUP BY repository_owner, repository_name, week;
+
+CREATE OR REPLACE VIEW conventional_commits_by_team AS
+SELECT t.team, date_trunc('week', cc.created_at) AS week,
+    count(*) AS commits,
+    count(cc.type) AS conventional,
+    round(100.0 * count(cc.type) / count(*), 2) AS adherence_percentage,
+    count(*) FILTER (WHERE cc.type = 'feat') AS feat,
+    count(*) FILTER (WHERE cc.type = 'fix') AS fix,
+    count(*) FILTER (WHERE cc.type = 'chore') AS chore,
+    count(*) FILTER (WHERE cc.type NOT IN ('feat', 'fix', 'chore')) AS other,
+    count(*) FILTER (WHERE cc.breaking) AS breaking_changes
+FROM conventional_commits cc
+INNER JOIN teams t ON t.member = cc.author
+GROUP BY t.team, week;
+
+CREATE OR REPLACE VIEW repo_change_failure_rate_weekly AS
+WITH deploys AS (
+    SELECT repository_owner, repository_name, date_trunc('week', created_at) AS week,
+        count(*) FILTER (WHERE failure_category IN ('success', 'failure')) AS deploys,
+        count(*) FILTER (WHERE failure_category = 'failure') AS failed_deploys
+    FROM workflow_runs w
+    WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = w.repository_owner AND w.repository_name LIKE i.slug)
+    GROUP BY repository_owner, repository_name, week
+), merges AS (
+    SELECT repository_owner, repository_name, date_trunc('week', merged_at) AS week,
+        count(*) AS merged_prs,
+        count(*) FILTER (WHERE title ILIKE 'revert%' OR branch_name ILIKE 'hotfix%') AS failure_prs
+    FROM metric_prs
+    WHERE state = 'MERGED'
+    GROUP BY repository_owner, repository_name, week
+)
+SELECT COALESCE(d.repository_owner, m.repository_owner) AS repository_owner,
+    COALESCE(d.repository_name, m.repository_name) AS repository_name,
+    COALESCE(d.week, m.week) AS week,
+    d.deploys, d.failed_deploys,
+    round(100.0 * d.failed_deploys / nullif(d.deploys, 0), 2) AS workflow_cfr_percentage,
+    m.merged_prs, m.failure_prs,
+    round(100.0 * m.failure_prs / nullif(m.merged_prs, 0), 2) AS pr_cfr_percentage
+FROM deploys d
+FULL OUTER JOIN merges m ON m.repository_owner = d.repository_owner AND m.repository_name = d.repository_name AND m.week = d.week;
+
+CREATE OR REPLACE VIEW merge_method_reverts AS
+SELECT p.repository_owner, p.repository_name, date_trunc('month', p.merged_at) AS month, p.merge_method,
+    count(*) AS merged,
+    count(*) FILTER (WHERE EXISTS (
+        SELECT 1 FROM metric_prs r
+        WHERE r.repository_owner = p.repository_owner AND r.repository_name = p.repository_name
+        AND r.title = 'Revert "' || p.title || '"' AND r.created_at > p.merged_at
+    )) AS reverted
+FROM metric_prs p
+WHERE p.state = 'MERGED' AND p.merge_method IS NOT NULL
+GROUP BY p.repository_owner, p.repository_name, month, p.merge_method;
+
+DROP INDEX IF EXISTS workflow_runs_repository_id_idx;
+
+ALTER TABLE workflow_runs DROP COLUMN IF EXISTS repository_id;
@@ -0,0 +1,81 @@
+-- filled from the runs' repository node id, the older runs are linked by the cronjob (SyncRepositoryRenames)
+ALTER TABLE workflow_runs ADD COLUMN IF NOT EXISTS repository_id TEXT;
+
+CREATE INDEX IF NOT EXISTS workflow_runs_repository_id_idx ON workflow_runs (repository_id);
+
+-- the repository metrics are grouped by repository id, so the history of renamed and transferred repositories
+-- is aggregated under their latest name; rows without an id are grouped by their name
+CREATE OR REPLACE VIEW conventional_commits AS
+SELECT c.id, c.pr_id, c.created_at, p.author, p.repository_owner, p.repository_name,
+    lower(substring(c.message FROM '^([A-Za-z]+)(?:\([^)]*\))?!?: ')) AS type,
+    c.message ~ '^[A-Za-z]+(\([^)]*\))?!: ' OR c.message ~ '(^|\n)BREAKING[ -]CHANGE: ' AS breaking,
+    p.repository_id
+FROM commits c
+INNER JOIN metric_prs p ON p.id = c.pr_id;
+
+CREATE OR REPLACE VIEW conventional_commits_by_repo AS
+SELECT (array_agg(repository_owner ORDER BY created_at DESC))[1] AS repository_owner,
+    (array_agg(repository_name ORDER BY created_at DESC))[1] AS repository_name,
+    date_trunc('week', created_at) AS week,
+    count(*) AS commits,
+    count(type) AS conventional,
+    round(100.0 * count(type) / count(*), 2) AS adherence_percentage,
+    count(*) FILTER (WHERE type = 'feat') AS feat,
+    count(*) FILTER (WHERE type = 'fix') AS fix,
+    count(*) FILTER (WHERE type = 'chore') AS chore,
+    count(*) FILTER (WHERE type NOT IN ('feat', 'fix', 'chore')) AS other,
+    count(*) FILTER (WHERE breaking) AS breaking_changes,
+    repository_id
+FROM conventional_commits
+GROUP BY repository_id, COALESCE(repository_id, repository_owner || '/' || repository_name), week;
+
+CREATE OR REPLACE VIEW repo_change_failure_rate_weekly AS
+WITH deploys AS (
+    SELECT COALESCE(w.repository_id, w.repository_owner || '/' || w.repository_name) AS repository,
+        (array_agg(w.repository_owner ORDER BY w.created_at DESC))[1] AS repository_owner,
+        (array_agg(w.repository_name ORDER BY w.created_at DESC))[1] AS repository_name,
+        date_trunc('week', w.created_at) AS week,
+        count(*) FILTER (WHERE w.failure_category IN ('success', 'failure')) AS deploys,
+        count(*) FILTER (WHERE w.failure_category = 'failure') AS failed_deploys,
+        max(w.repository_id) AS repository_id
+    FROM workflow_runs w
+    WHERE NOT EXISTS (SELECT 1 FROM ignored_repositories i WHERE i.org = w.repository_owner AND w.repository_name LIKE i.slug)
+    GROUP BY repository, week
+), merges AS (
+    SELECT COALESCE(p.repository_id, p.repository_owner || '/' || p.repository_name) AS repository,
+        (array_agg(p.repository_owner ORDER BY p.merged_at DESC))[1] AS repository_owner,
+        (array_agg(p.repository_name ORDER BY p.merged_at DESC))[1] AS repository_name,
+        date_trunc('week', p.merged_at) AS week,
+        count(*) AS merged_prs,
+        count(*) FILTER (WHERE p.title ILIKE 'revert%' OR p.branch_name ILIKE 'hotfix%') AS failure_prs,
+        max(p.repository_id) AS repository_id
+    FROM metric_prs p
+    WHERE p.state = 'MERGED'
+    GROUP BY repository, week
+)
+SELECT COALESCE(r.org, m.repository_owner, d.repository_owner) AS repository_owner,
+    COALESCE(r.slug, m.repository_name, d.repository_name) AS repository_name,
+    COALESCE(d.week, m.week) AS week,
+    d.deploys, d.failed_deploys,
+    round(100.0 * d.failed_deploys / nullif(d.deploys, 0), 2) AS workflow_cfr_percentage,
+    m.merged_prs, m.failure_prs,
+    round(100.0 * m.failure_prs / nullif(m.merged_prs, 0), 2) AS pr_cfr_percentage,
+    COALESCE(d.repository_id, m.repository_id) AS repository_id
+FROM deploys d
+FULL OUTER JOIN merges m ON m.repository = d.repository AND m.week = d.week
+LEFT JOIN repositories r ON r.id = COALESCE(d.repository_id, m.repository_id);
+
+CREATE OR REPLACE VIEW merge_method_reverts AS
+SELECT (array_agg(p.repository_owner ORDER BY p.merged_at DESC))[1] AS repository_owner,
+    (array_agg(p.repository_name ORDER BY p.merged_at DESC))[1] AS repository_name,
+    date_trunc('month', p.merged_at) AS month, p.merge_method,
+    count(*) AS merged,
+    count(*) FILTER (WHERE EXISTS (
+        SELECT 1 FROM metric_prs r
+        WHERE COALESCE(r.repository_id, r.repository_owner || '/' || r.repository_name) = COALESCE(p.repository_id, p.repository_owner || '/' || p.repository_name)
+        AND r.title = 'Revert "' || p.title || '"' AND r.created_at > p.merged_at
+    )) AS reverted,
+    p.repository_id
+FROM metric_prs p
+WHERE p.state = 'MERGED' AND p.merge_method IS NOT NULL
+GROUP BY p.repository_id, COALESCE(p.repository_id, p.repository_owner || '/' || p.repository_name), month, p.merge_method;
@@ -0,0 +1,3 @@
+DELETE FROM repositories WHERE archived;
+
+ALTER TABLE repositories DROP COLUMN IF EXISTS archived;
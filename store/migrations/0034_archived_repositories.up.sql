@@ -0,0 +1,2 @@
+-- archived repositories are stored too, so their pull requests aren't reported as pointing at a missing repository
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS team_ignored_review_requests_weekly;
+DROP VIEW IF EXISTS ignored_review_requests;
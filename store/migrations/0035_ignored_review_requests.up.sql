@@ -0,0 +1,31 @@
+-- review requests of closed or merged pull requests nobody answered: a user request is answered by a review of that
+-- user, a team request by a review of any member of the team, submitted after the request; open pull requests are
+-- left out, their requests may still be answered
+CREATE OR REPLACE VIEW ignored_review_requests AS
+SELECT rr.pr_id, p.url, p.title, p.author, rr.reviewer, rr.reviewer_type, rr.requested_at,
+    p.repository_owner, p.repository_name, COALESCE(p.merged_at, p.closed_at) AS finished_at
+FROM review_requests rr
+INNER JOIN metric_prs p ON p.id = rr.pr_id
+WHERE p.state <> 'OPEN'
+AND NOT EXISTS (
+    SELECT 1 FROM reviews r
+    WHERE r.pr_id = rr.pr_id AND r.state <> 'PENDING' AND r.submitted_at >= rr.requested_at
+    AND (r.author = rr.reviewer OR (rr.reviewer_type = 'Team' AND EXISTS (
+        SELECT 1 FROM teams t WHERE t.team = rr.reviewer AND t.member = r.author
+    )))
+);
+
+-- requests each team was asked to answer, a user request counts for every team of the reviewer
+CREATE OR REPLACE VIEW team_ignored_review_requests_weekly AS
+SELECT t.team, date_trunc('week', rr.requested_at) AS week, count(*) AS requested,
+    count(i.pr_id) AS ignored,
+    round(100.0 * count(i.pr_id) / count(*), 2) AS ignored_percentage
+FROM review_requests rr
+INNER JOIN metric_prs p ON p.id = rr.pr_id
+INNER JOIN LATERAL (
+    SELECT DISTINCT team FROM teams
+    WHERE (rr.reviewer_type = 'User' AND member = rr.reviewer) OR (rr.reviewer_type = 'Team' AND team = rr.reviewer)
+) t ON true
+LEFT JOIN ignored_review_requests i ON i.pr_id = rr.pr_id AND i.reviewer = rr.reviewer AND i.requested_at = rr.requested_at
+WHERE p.state <> 'OPEN'
+GROUP BY t.team, week;
@@ -4,14 +4,19 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
-	"os"
 	"slices"
+	"strings"
 	"time"
 
+	"github.com/akawula/DoraMatic/compliance"
+	"github.com/akawula/DoraMatic/events"
+	"github.com/akawula/DoraMatic/github/organizations"
 	"github.com/akawula/DoraMatic/github/pullrequests"
 	"github.com/akawula/DoraMatic/github/repositories"
+	"github.com/akawula/DoraMatic/github/workflows"
+	"github.com/akawula/DoraMatic/internal/config"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DBRepository struct {
@@ -25,29 +30,43 @@ type Count struct {
 }
 
 type Postgres struct {
-	db     *sqlx.DB
-	Logger *slog.Logger
+	db        *sqlx.DB
+	replica   *replica
+	publisher events.Publisher
+	Logger    *slog.Logger
 }
 
-func NewPostgres(logger *slog.Logger) Store {
-	connection := fmt.Sprintf("user=%s dbname=%s sslmode=disable password=%s host=%s port=%s", os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_DB"), os.Getenv("POSTGRES_PASSWORD"), os.Getenv("POSTGRES_SERVICE_HOST"), os.Getenv("POSTGRES_SERVICE_PORT"))
-	db, err := sqlx.Connect("postgres", connection)
+func connectionString(cfg config.Postgres, host string, port string) string {
+	return fmt.Sprintf("user=%s dbname=%s sslmode=disable password=%s host=%s port=%s", cfg.User, cfg.DB, cfg.Password, host, port)
+}
+
+func NewPostgres(cfg config.Postgres, logger *slog.Logger) Store {
+	db, err := sqlx.Connect("postgres", connectionString(cfg, cfg.Host, cfg.Port))
 	if err != nil {
 		logger.Error("can't connect to postgres", "error", err)
 	}
 
-	return &Postgres{db: db, Logger: logger}
+	return &Postgres{db: db, replica: newReplica(cfg, logger), publisher: events.Noop{}, Logger: logger}
+}
+
+// CheckSchema verifies that the migrations were applied up to the latest embedded version.
+func (p *Postgres) CheckSchema() error {
+	m := &Migrator{db: p.db, Logger: p.Logger}
+	return m.Check()
 }
 
 func (p *Postgres) Close() {
 	p.db.Close()
+	if p.replica != nil {
+		p.replica.db.Close()
+	}
 }
 
 func (p *Postgres) getTotal(q string) int {
 	t := Count{}
 	p.Logger.Debug("Executing total query", "query", q)
 
-	if err := p.db.Get(&t, q); err != nil {
+	if err := p.reader().Get(&t, q); err != nil {
 		p.Logger.Error("can't calculate total", "error", err)
 		return 0
 	}
@@ -56,6 +75,9 @@ func (p *Postgres) getTotal(q string) int {
 }
 
 func (p *Postgres) GetRepos(page int, search string) ([]DBRepository, int, error) {
+	if page < 1 {
+		return nil, 0, fmt.Errorf("%w: page %d, pages start at 1", ErrInvalidArgument, page)
+	}
 	repos := []DBRepository{}
 	limit := 20 // TODO: someday make it a param from echo, so customer can choose how many rows to show at once
 	offset := calculateOffset(page, limit)
@@ -65,9 +87,9 @@ func (p *Postgres) GetRepos(page int, search string) ([]DBRepository, int, error
 
 	total := p.getTotal(queryTotal)
 
-	if err := p.db.Select(&repos, query+lo); err != nil {
+	if err := p.reader().Select(&repos, query+lo); err != nil {
 		p.Logger.Error("can't fetch repositories", "error", err)
-		return nil, 0, err
+		return nil, 0, wrapErr(err)
 	}
 
 	return repos, total, nil
@@ -77,14 +99,14 @@ func (p *Postgres) SaveRepos(repos []repositories.Repository) error {
 	p.db.MustExec("TRUNCATE repositories")
 	batchUpdate := []map[string]interface{}{}
 	for _, repo := range repos {
-		batchUpdate = append(batchUpdate, map[string]interface{}{"org": repo.Owner.Login, "slug": string(repo.Name), "language": string(repo.PrimaryLanguage.Name)})
+		batchUpdate = append(batchUpdate, map[string]interface{}{"id": string(repo.Id), "org": repo.Owner.Login, "slug": string(repo.Name), "language": string(repo.PrimaryLanguage.Name), "archived": bool(repo.IsArchived)})
 	}
 
-	_, err := p.db.NamedExec(`INSERT INTO repositories (org, slug, language)
-    VALUES (:org, :slug, :language)`, batchUpdate)
+	_, err := p.db.NamedExec(`INSERT INTO repositories (id, org, slug, language, archived)
+    VALUES (:id, :org, :slug, :language, :archived)`, batchUpdate)
 	if err != nil {
 		p.Logger.Error("can't insert new repository", "error", err)
-		return err
+		return wrapErr(err)
 	}
 	return nil
 }
@@ -109,93 +131,559 @@ func (p *Postgres) GetLastPRDate(org string, repo string) (t time.Time) {
 	return
 }
 
-func (p *Postgres) SavePullRequest(prs []pullrequests.PullRequest) (err error) {
+// GetOldestOpenPRDate returns when the oldest pull request stored as OPEN was created, false when there is none.
+func (p *Postgres) GetOldestOpenPRDate(org string, repo string) (time.Time, bool) {
+	t := sql.NullTime{}
+	err := p.db.Get(&t, `SELECT min(created_at) FROM prs WHERE state = 'OPEN' AND repository_owner = $1 AND repository_name = $2`, org, repo)
+	if err != nil {
+		p.Logger.Error("can't fetch the date of the oldest open pr", "error", err, "repo", repo, "org", org)
+	}
+
+	return t.Time, t.Valid
+}
+
+// GetOpenPRIds returns the node ids of the pull requests of the organizations stored as OPEN.
+func (p *Postgres) GetOpenPRIds(orgs []string) ([]string, error) {
+	ids := []string{}
+	err := p.db.Select(&ids, `SELECT id FROM prs WHERE state = 'OPEN' AND repository_owner = ANY($1)`, pq.Array(orgs))
+
+	return ids, wrapErr(err)
+}
+
+// SavePullRequest upserts the pull requests with their commits, reviews, timeline events, JIRA references and closed issues,
+// each one in its own savepoint, so a pull request that can't be saved is skipped without losing the others.
+// The error is returned only when nothing could be saved, the skipped pull requests are in the result. The saved ones are published once committed.
+func (p *Postgres) SavePullRequest(prs []pullrequests.PullRequest) (SaveResult, error) {
+	result := SaveResult{}
 	if len(prs) == 0 {
 		p.Logger.Info("Pull Requests slice is empty, going next...")
-		return
+		return result, nil
 	}
 
-	batchUpdate := []map[string]interface{}{}
+	tx, err := p.db.Beginx()
+	if err != nil {
+		return result, wrapErr(err)
+	}
+	defer tx.Rollback()
+
+	saved := []events.Event{}
 	for _, pr := range prs {
-		var review_at sql.NullString
-		var merged_at sql.NullString
-		if len(pr.TimelineItems.Nodes) > 0 {
-			review_at = sql.NullString{
-				String: string(pr.TimelineItems.Nodes[0].ReviewRequestedEventFragment.CreatedAt),
-				Valid:  true,
+		if _, err = tx.Exec(`SAVEPOINT pr`); err != nil {
+			return result, wrapErr(err)
+		}
+
+		inserted, err := p.savePullRequest(tx, pr)
+		if err != nil {
+			p.Logger.Error("can't save the pull request, skipping it", "pr", pr.Id, "error", err)
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT pr`); err != nil {
+				return result, wrapErr(err)
 			}
+			result.Skipped++
+			result.Errors = append(result.Errors, PRError{PrId: string(pr.Id), Err: wrapErr(err)})
+			continue
+		}
+
+		if _, err = tx.Exec(`RELEASE SAVEPOINT pr`); err != nil {
+			return result, wrapErr(err)
+		}
+		if inserted {
+			result.Inserted++
+		} else {
+			result.Updated++
+		}
+		saved = append(saved, prEvents(pr, time.Now())...)
+	}
+	if result.Inserted+result.Updated == 0 && result.Skipped > 0 {
+		return result, result.Err()
+	}
+
+	if err = tx.Commit(); err != nil {
+		p.Logger.Error("can't commit the pull requests", "error", err)
+		return SaveResult{}, wrapErr(err)
+	}
+	p.publish(saved)
+
+	return result, nil
+}
+
+// savePullRequest upserts a single pull request and its children, it reports whether the pull request is new.
+func (p *Postgres) savePullRequest(tx *sqlx.Tx, pr pullrequests.PullRequest) (inserted bool, err error) {
+	var review_at sql.NullString
+	var merged_at sql.NullString
+	if len(pr.TimelineItems.Nodes) > 0 {
+		review_at = sql.NullString{
+			String: string(pr.TimelineItems.Nodes[0].ReviewRequestedEventFragment.CreatedAt),
+			Valid:  true,
+		}
+	}
+	if len(pr.MergedAt) > 0 {
+		merged_at = sql.NullString{
+			String: string(pr.MergedAt),
+			Valid:  true,
+		}
+	}
+	closed_at := sql.NullString{String: string(pr.ClosedAt), Valid: len(pr.ClosedAt) > 0}
+	var approved_at sql.NullString
+	if first := pr.FirstApprovedAt(); len(first) > 0 {
+		approved_at = sql.NullString{
+			String: string(first),
+			Valid:  true,
+		}
+	}
+	labels := []string{}
+	for _, label := range pr.Labels.Nodes {
+		labels = append(labels, string(label.Name))
+	}
+	values := map[string]interface{}{
+		"id":                  pr.Id,
+		"url":                 pr.Url,
+		"title":               pr.Title,
+		"state":               pr.State,
+		"author":              normalizeLogin(string(pr.Author.Login)),
+		"additions":           pr.Additions,
+		"deletions":           pr.Deletions,
+		"changed_files":       pr.ChangedFiles,
+		"merged_at":           merged_at,
+		"closed_at":           closed_at,
+		"created_at":          pr.CreatedAt,
+		"branch_name":         pr.HeadRefName,
+		"merge_commit_sha":    sql.NullString{String: string(pr.MergeCommit.Oid), Valid: len(pr.MergeCommit.Oid) > 0},
+		"merged_by":           sql.NullString{String: string(pr.MergedBy.Login), Valid: len(pr.MergedBy.Login) > 0},
+		"merge_method":        sql.NullString{String: pr.MergeMethod(), Valid: len(pr.MergeMethod()) > 0},
+		"repository_name":     pr.Repository.Name,
+		"repository_owner":    pr.Repository.Owner.Login,
+		"repository_id":       sql.NullString{String: string(pr.Repository.Id), Valid: len(pr.Repository.Id) > 0},
+		"reviews_requested":   pr.TimelineItems.TotalCount,
+		"review_requested_at": review_at,
+		"labels":              pq.Array(labels),
+		"first_approved_at":   approved_at,
+	}
+
+	// xmax is 0 only for the rows inserted by this statement
+	query, args, err := tx.BindNamed(`INSERT INTO prs (id, title, state, url, merged_at, closed_at, created_at, additions, deletions, branch_name, author, repository_name, repository_owner, repository_id, review_requested_at, reviews_requested, labels, first_approved_at, merge_commit_sha, merged_by, merge_method, changed_files)
+    VALUES (:id, :title, :state, :url, :merged_at, :closed_at, :created_at, :additions, :deletions, :branch_name, :author, :repository_name, :repository_owner, :repository_id, :review_requested_at, :reviews_requested, :labels, :first_approved_at, :merge_commit_sha, :merged_by, :merge_method, :changed_files) 
+    ON CONFLICT (id) 
+    DO UPDATE 
+    SET title = EXCLUDED.title, repository_name = EXCLUDED.repository_name, repository_owner = EXCLUDED.repository_owner, repository_id = EXCLUDED.repository_id, state = EXCLUDED.state, merged_at = EXCLUDED.merged_at, closed_at = EXCLUDED.closed_at, additions = EXCLUDED.additions, deletions = EXCLUDED.deletions, review_requested_at = EXCLUDED.review_requested_at, reviews_requested = EXCLUDED.reviews_requested, labels = EXCLUDED.labels, first_approved_at = EXCLUDED.first_approved_at, merge_commit_sha = EXCLUDED.merge_commit_sha, merged_by = EXCLUDED.merged_by, merge_method = EXCLUDED.merge_method, changed_files = EXCLUDED.changed_files
+    RETURNING (xmax = 0) AS inserted`, values)
+	if err != nil {
+		return false, err
+	}
+	if err = tx.Get(&inserted, query, args...); err != nil {
+		return false, fmt.Errorf("pull request: %w", err)
+	}
+
+	if len(pr.Commits.Nodes) > 0 {
+		if err = saveCommits(tx, string(pr.Id), pr.Commits.Nodes); err != nil {
+			return false, fmt.Errorf("commits: %w", err)
+		}
+	}
+
+	if len(pr.Reviews.Nodes) > 0 {
+		if err = saveReviews(tx, string(pr.Id), pr.Reviews.Nodes); err != nil {
+			return false, fmt.Errorf("reviews: %w", err)
+		}
+	}
+
+	if len(pr.TimelineItems.Nodes) > 0 {
+		if err = saveReviewRequests(tx, string(pr.Id), pr.TimelineItems.Nodes); err != nil {
+			return false, fmt.Errorf("review requests: %w", err)
+		}
+	}
+
+	if len(pr.LabelEvents.Nodes) > 0 {
+		if err = saveLabelEvents(tx, string(pr.Id), pr.LabelEvents.Nodes); err != nil {
+			return false, fmt.Errorf("label events: %w", err)
 		}
-		if len(pr.MergedAt) > 0 {
-			merged_at = sql.NullString{
-				String: string(pr.MergedAt),
+	}
+
+	if err = replaceJiraRefs(tx, []string{string(pr.Id)}, prJiraRefs(pr)); err != nil {
+		return false, fmt.Errorf("JIRA references: %w", err)
+	}
+
+	if err = replaceIssueLinks(tx, string(pr.Id), pr.ClosingIssuesReferences.Nodes); err != nil {
+		return false, fmt.Errorf("issue links: %w", err)
+	}
+
+	return inserted, nil
+}
+
+func saveCommits(e sqlx.Ext, pr_id string, commits []pullrequests.Commit) (err error) {
+	batchUpdate := []map[string]interface{}{}
+	for _, commit := range commits {
+		batchUpdate = append(batchUpdate, map[string]interface{}{
+			"id":         string(commit.Id),
+			"pr_id":      pr_id,
+			"message":    commit.Commit.Message,
+			"created_at": string(commit.Commit.CommittedDate),
+			"additions":  commit.Commit.Additions,
+			"deletions":  commit.Commit.Deletions,
+		})
+	}
+
+	_, err = sqlx.NamedExec(e, `INSERT INTO commits (id, pr_id, message, created_at, additions, deletions)
+    VALUES (:id, :pr_id, :message, :created_at, :additions, :deletions) ON CONFLICT (id) DO UPDATE SET created_at = EXCLUDED.created_at, additions = EXCLUDED.additions, deletions = EXCLUDED.deletions`, batchUpdate)
+	return
+}
+
+func saveReviews(e sqlx.Ext, pr_id string, reviews []pullrequests.Review) (err error) {
+	batchUpdate := []map[string]interface{}{}
+	for _, review := range reviews {
+		var submitted_at sql.NullString
+		if len(review.SubmittedAt) > 0 {
+			submitted_at = sql.NullString{
+				String: string(review.SubmittedAt),
 				Valid:  true,
 			}
 		}
 		batchUpdate = append(batchUpdate, map[string]interface{}{
-			"id":                  pr.Id,
-			"url":                 pr.Url,
-			"title":               pr.Title,
-			"state":               pr.State,
-			"author":              pr.Author.Login,
-			"additions":           pr.Additions,
-			"deletions":           pr.Deletions,
-			"merged_at":           merged_at,
-			"created_at":          pr.CreatedAt,
-			"branch_name":         pr.HeadRefName,
-			"repository_name":     pr.Repository.Name,
-			"repository_owner":    pr.Repository.Owner.Login,
-			"reviews_requested":   pr.TimelineItems.TotalCount,
-			"review_requested_at": review_at,
+			"id":           string(review.Id),
+			"pr_id":        pr_id,
+			"author":       normalizeLogin(string(review.Author.Login)),
+			"state":        string(review.State),
+			"submitted_at": submitted_at,
 		})
+	}
 
-		if len(pr.Commits.Nodes) > 0 {
-			err = p.SaveCommits(string(pr.Id), pr.Commits.Nodes)
-		}
+	_, err = sqlx.NamedExec(e, `INSERT INTO reviews (id, pr_id, author, state, submitted_at)
+    VALUES (:id, :pr_id, :author, :state, :submitted_at) ON CONFLICT (id) DO UPDATE SET state = EXCLUDED.state, submitted_at = EXCLUDED.submitted_at`, batchUpdate)
+	return
+}
 
-		if err != nil {
-			p.Logger.Error("can't save commits", "pr", pr.Id, "commits", pr.Commits.Nodes)
+func saveReviewRequests(e sqlx.Ext, pr_id string, requests []pullrequests.ReviewRequest) (err error) {
+	batchUpdate := []map[string]interface{}{}
+	for _, request := range requests {
+		event := request.ReviewRequestedEventFragment
+		reviewer, reviewerType := string(event.RequestedReviewer.User.Login), "User"
+		if len(reviewer) == 0 {
+			reviewer, reviewerType = string(event.RequestedReviewer.Team.Name), "Team"
+		}
+		if len(reviewer) == 0 {
+			continue // reviewer was deleted or is a bot/mannequin we don't track
 		}
+		batchUpdate = append(batchUpdate, map[string]interface{}{
+			"pr_id":         pr_id,
+			"reviewer":      reviewer,
+			"reviewer_type": reviewerType,
+			"requested_at":  string(event.CreatedAt),
+		})
 	}
 
-	for _, vals := range slices.Collect(slices.Chunk(batchUpdate, (2<<15-1)/14)) { // chunk the batchUpdate 65k / # of params (14 currently)
-		_, err = p.db.NamedExec(`INSERT INTO prs (id, title, state, url, merged_at, created_at, additions, deletions, branch_name, author, repository_name, repository_owner, review_requested_at, reviews_requested)
-    VALUES (:id, :title, :state, :url, :merged_at, :created_at, :additions, :deletions, :branch_name, :author, :repository_name, :repository_owner, :review_requested_at, :reviews_requested) 
-    ON CONFLICT (id) 
-    DO UPDATE 
-    SET title = EXCLUDED.title, state = EXCLUDED.state, merged_at = EXCLUDED.merged_at, additions = EXCLUDED.additions, deletions = EXCLUDED.deletions, review_requested_at = EXCLUDED.review_requested_at, reviews_requested = EXCLUDED.reviews_requested`, vals)
-		if err != nil {
-			p.Logger.Error("can't insert new pull request", "error", err)
-			return
+	if len(batchUpdate) == 0 {
+		return
+	}
+
+	_, err = sqlx.NamedExec(e, `INSERT INTO review_requests (pr_id, reviewer, reviewer_type, requested_at)
+    VALUES (:pr_id, :reviewer, :reviewer_type, :requested_at) ON CONFLICT (pr_id, reviewer, requested_at) DO NOTHING`, batchUpdate)
+	return
+}
+
+func saveLabelEvents(e sqlx.Ext, pr_id string, events []pullrequests.LabelEvent) (err error) {
+	batchUpdate := []map[string]interface{}{}
+	for _, event := range events {
+		label, action, createdAt := event.LabeledEventFragment.Label.Name, "LABELED", event.LabeledEventFragment.CreatedAt
+		if len(createdAt) == 0 {
+			label, action, createdAt = event.UnlabeledEventFragment.Label.Name, "UNLABELED", event.UnlabeledEventFragment.CreatedAt
+		}
+		if len(label) == 0 || len(createdAt) == 0 {
+			continue // label was deleted since
 		}
+		batchUpdate = append(batchUpdate, map[string]interface{}{
+			"pr_id":      pr_id,
+			"label":      string(label),
+			"action":     action,
+			"created_at": string(createdAt),
+		})
+	}
+
+	if len(batchUpdate) == 0 {
+		return
 	}
 
+	_, err = sqlx.NamedExec(e, `INSERT INTO pr_label_events (pr_id, label, action, created_at)
+    VALUES (:pr_id, :label, :action, :created_at) ON CONFLICT (pr_id, label, action, created_at) DO NOTHING`, batchUpdate)
 	return
 }
 
-func (p *Postgres) SaveCommits(pr_id string, commits []pullrequests.Commit) (err error) {
+// replaceIssueLinks replaces the issues the pull request closes, so an unlinked issue doesn't linger.
+func replaceIssueLinks(e sqlx.Ext, pr_id string, issues []pullrequests.Issue) (err error) {
+	if _, err = e.Exec(`DELETE FROM pr_issue_links WHERE pr_id = $1`, pr_id); err != nil {
+		return
+	}
+
 	batchUpdate := []map[string]interface{}{}
-	for _, commit := range commits {
+	for _, issue := range issues {
+		if len(issue.Url) == 0 {
+			continue // issue was deleted or isn't accessible to the token
+		}
 		batchUpdate = append(batchUpdate, map[string]interface{}{
-			"id":      string(commit.Id),
-			"pr_id":   pr_id,
-			"message": commit.Commit.Message,
+			"pr_id":      pr_id,
+			"issue_url":  string(issue.Url),
+			"repository": string(issue.Repository.NameWithOwner),
+			"number":     issue.Number,
+			"created_at": string(issue.CreatedAt),
+			"closed_at":  sql.NullString{String: string(issue.ClosedAt), Valid: len(issue.ClosedAt) > 0},
 		})
 	}
 
-	_, err = p.db.NamedExec(`INSERT INTO commits (id, pr_id, message)
-    VALUES (:id, :pr_id, :message) ON CONFLICT (id) DO NOTHING`, batchUpdate)
-	if err != nil {
-		p.Logger.Error("can't insert new commit", "error", err)
+	if len(batchUpdate) == 0 {
 		return
 	}
+
+	_, err = sqlx.NamedExec(e, `INSERT INTO pr_issue_links (pr_id, issue_url, repository, number, created_at, closed_at)
+    VALUES (:pr_id, :issue_url, :repository, :number, :created_at, :closed_at) ON CONFLICT (pr_id, issue_url) DO NOTHING`, batchUpdate)
 	return
 }
 
+// GetLastWorkflowRunDate returns since when the workflow runs of the repository have to be fetched again,
+// that's the oldest unfinished run or the newest one when all of them are completed.
+func (p *Postgres) GetLastWorkflowRunDate(org string, repo string) time.Time {
+	t := sql.NullTime{}
+	err := p.db.Get(&t, `SELECT COALESCE(min(created_at) FILTER (WHERE status <> 'completed'), max(created_at)) FROM workflow_runs
+WHERE repository_owner = $1 AND repository_name = $2`, org, repo)
+	if err != nil {
+		p.Logger.Error("can't fetch last date of workflow run", "error", err, "repo", repo, "org", org)
+	}
+	if !t.Valid {
+		return time.Now().AddDate(-2, 0, 0) // -2 years
+	}
+
+	return t.Time
+}
+
+func (p *Postgres) SaveWorkflowRuns(runs []workflows.Run) error {
+	batchUpdate := []map[string]interface{}{}
+	for _, run := range runs {
+		batchUpdate = append(batchUpdate, map[string]interface{}{
+			"id":               run.Id,
+			"repository_owner": run.Repository.Owner.Login,
+			"repository_name":  run.Repository.Name,
+			"repository_id":    sql.NullString{String: run.Repository.NodeId, Valid: len(run.Repository.NodeId) > 0},
+			"workflow_name":    run.Name,
+			"head_branch":      run.HeadBranch,
+			"head_sha":         run.HeadSha,
+			"event":            run.Event,
+			"status":           run.Status,
+			"conclusion":       sql.NullString{String: run.Conclusion, Valid: len(run.Conclusion) > 0},
+			"url":              run.HtmlUrl,
+			"created_at":       run.CreatedAt,
+			"updated_at":       run.UpdatedAt,
+			"run_started_at":   sql.NullTime{Time: run.RunStartedAt, Valid: !run.RunStartedAt.IsZero()},
+			"failure_category": sql.NullString{String: run.FailureCategory(), Valid: len(run.FailureCategory()) > 0},
+		})
+	}
+
+	for _, vals := range slices.Collect(slices.Chunk(batchUpdate, (2<<15-1)/15)) { // chunk the batchUpdate 65k / # of params (15 currently)
+		_, err := p.db.NamedExec(`INSERT INTO workflow_runs (id, repository_owner, repository_name, repository_id, workflow_name, head_branch, head_sha, event, status, conclusion, url, created_at, updated_at, run_started_at, failure_category)
+    VALUES (:id, :repository_owner, :repository_name, :repository_id, :workflow_name, :head_branch, :head_sha, :event, :status, :conclusion, :url, :created_at, :updated_at, :run_started_at, :failure_category)
+    ON CONFLICT (id)
+    DO UPDATE
+    SET status = EXCLUDED.status, conclusion = EXCLUDED.conclusion, updated_at = EXCLUDED.updated_at, run_started_at = EXCLUDED.run_started_at, failure_category = EXCLUDED.failure_category, repository_id = COALESCE(EXCLUDED.repository_id, workflow_runs.repository_id)`, vals)
+		if err != nil {
+			p.Logger.Error("can't insert new workflow run", "error", err)
+			return wrapErr(err)
+		}
+	}
+	p.publish(runEvents(runs, time.Now()))
+
+	return nil
+}
+
+// SaveIdentities replaces the SAML identities of the members.
+func (p *Postgres) SaveIdentities(identities []organizations.Identity) error {
+	tx, err := p.db.Beginx()
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer tx.Rollback()
+
+	tx.MustExec("TRUNCATE member_identities")
+	for _, vals := range slices.Collect(slices.Chunk(identities, (2<<15-1)/4)) { // chunk the identities 65k / # of params (4 currently)
+		_, err = tx.NamedExec(`INSERT INTO member_identities (org, login, name_id, email)
+    VALUES (:org, :login, :nameid, :email) ON CONFLICT (org, login) DO NOTHING`, vals)
+		if err != nil {
+			p.Logger.Error("can't insert member identity", "error", err)
+			return wrapErr(err)
+		}
+	}
+
+	return wrapErr(tx.Commit())
+}
+
+// SaveIgnoredRepos replaces the repositories excluded from the metrics.
+func (p *Postgres) SaveIgnoredRepos(patterns []config.RepoPattern) error {
+	tx, err := p.db.Beginx()
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer tx.Rollback()
+
+	tx.MustExec("TRUNCATE ignored_repositories")
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	for _, pattern := range patterns {
+		if _, err = tx.Exec(`INSERT INTO ignored_repositories (org, slug) VALUES ($1, $2) ON CONFLICT DO NOTHING`, pattern.Org, replacer.Replace(pattern.Slug)); err != nil {
+			p.Logger.Error("can't insert ignored repository", "error", err)
+			return wrapErr(err)
+		}
+	}
+
+	return wrapErr(tx.Commit())
+}
+
+// SaveBlockingLabels replaces the labels whose time is reported as blocked.
+func (p *Postgres) SaveBlockingLabels(labels []string) error {
+	tx, err := p.db.Beginx()
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer tx.Rollback()
+
+	tx.MustExec("TRUNCATE blocking_labels")
+	for _, label := range labels {
+		if _, err = tx.Exec(`INSERT INTO blocking_labels (label) VALUES ($1) ON CONFLICT DO NOTHING`, strings.ToLower(label)); err != nil {
+			p.Logger.Error("can't insert blocking label", "error", err)
+			return wrapErr(err)
+		}
+	}
+
+	return wrapErr(tx.Commit())
+}
+
+func (p *Postgres) SaveCompliance(results []compliance.Result) error {
+	tx, err := p.db.Beginx()
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer tx.Rollback()
+
+	for _, result := range results {
+		if _, err = tx.Exec(`UPDATE prs SET compliance_score = $1, compliance_failed = $2 WHERE id = $3`, result.Score, pq.Array(result.Failed), result.PrId); err != nil {
+			p.Logger.Error("can't save pull request compliance", "pr", result.PrId, "error", err)
+			return wrapErr(err)
+		}
+	}
+
+	return wrapErr(tx.Commit())
+}
+
+// RecomputeDerivedColumns precomputes the first commit/review dates, the lead time segments, the churn, the review cycles
+// and the blocked time of every pull request.
+// Churn is how many more lines the commits changed than the final diff, it only counts the fetched (first 50) commits.
+// Review cycles are unknown (NULL) for the pull requests without reviews, the ones stored before reviews were synced.
+// Blocked time runs from a blocking label being added until it's removed or the pull request is merged or closed,
+// the intervals of several blocking labels are merged so they aren't counted twice.
+func (p *Postgres) RecomputeDerivedColumns() (int64, error) {
+	res, err := p.db.Exec(`UPDATE prs p
+SET first_commit_at = d.first_commit_at,
+    first_review_at = d.first_review_at,
+    lead_time_to_code = EXTRACT(EPOCH FROM p.created_at - d.first_commit_at)::BIGINT,
+    lead_time_to_review = EXTRACT(EPOCH FROM d.first_review_at - p.created_at)::BIGINT,
+    lead_time_to_merge = EXTRACT(EPOCH FROM p.merged_at - d.first_review_at)::BIGINT,
+    churn = d.churn,
+    review_cycles = d.review_cycles,
+    blocked_seconds = d.blocked_seconds
+FROM (
+    SELECT pr.id,
+        (SELECT min(c.created_at) FROM commits c WHERE c.pr_id = pr.id) AS first_commit_at,
+        (SELECT min(r.submitted_at) FROM reviews r WHERE r.pr_id = pr.id AND r.author <> pr.author) AS first_review_at,
+        (SELECT GREATEST(sum(c.additions + c.deletions) - (pr.additions + pr.deletions), 0) FROM commits c WHERE c.pr_id = pr.id HAVING count(c.additions) > 0) AS churn,
+        (SELECT count(*) FILTER (WHERE r.author <> pr.author AND r.state = 'CHANGES_REQUESTED'
+            AND EXISTS (SELECT 1 FROM commits c WHERE c.pr_id = pr.id AND c.created_at > r.submitted_at
+                AND EXISTS (SELECT 1 FROM reviews rr WHERE rr.pr_id = pr.id AND rr.author <> pr.author AND rr.submitted_at > c.created_at)))
+            FROM reviews r WHERE r.pr_id = pr.id HAVING count(*) > 0) AS review_cycles,
+        (SELECT COALESCE(sum(EXTRACT(EPOCH FROM upper(r) - lower(r))), 0)::BIGINT
+            FROM unnest((SELECT range_agg(tstzrange(b.created_at, COALESCE(b.next_at, pr.merged_at, pr.closed_at, now())))
+                FROM (SELECT e.action, e.created_at, lead(e.created_at) OVER (PARTITION BY bl.label ORDER BY e.created_at) AS next_at
+                    FROM pr_label_events e
+                    INNER JOIN blocking_labels bl ON bl.label = lower(e.label)
+                    WHERE e.pr_id = pr.id) b
+                WHERE b.action = 'LABELED' AND b.created_at < COALESCE(b.next_at, pr.merged_at, pr.closed_at, now()))) r) AS blocked_seconds
+    FROM prs pr
+) d
+WHERE p.id = d.id
+AND (p.first_commit_at, p.first_review_at, p.lead_time_to_merge, p.churn, p.review_cycles, p.blocked_seconds) IS DISTINCT FROM (d.first_commit_at, d.first_review_at, EXTRACT(EPOCH FROM p.merged_at - d.first_review_at)::BIGINT, d.churn, d.review_cycles, d.blocked_seconds)`)
+	if err != nil {
+		p.Logger.Error("can't recompute derived columns", "error", err)
+		return 0, wrapErr(err)
+	}
+
+	return res.RowsAffected()
+}
+
+// SyncRepositoryRenames moves the history of renamed or transferred repositories to their current name,
+// the previous names are kept in repository_aliases. It has to run after SaveRepos and SaveRepositoryAlias.
+func (p *Postgres) SyncRepositoryRenames() (int64, error) {
+	tx, err := p.db.Beginx()
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+	defer tx.Rollback()
+
+	// pull requests and workflow runs stored before ids were tracked, matched by their current or a previous name
+	for _, table := range []string{"prs", "workflow_runs"} {
+		if _, err = tx.Exec(fmt.Sprintf(`UPDATE %[1]s p SET repository_id = n.id FROM (
+    SELECT id, org, slug FROM repositories WHERE id IS NOT NULL
+    UNION ALL
+    SELECT a.repository_id, a.org, a.slug FROM repository_aliases a
+    WHERE NOT EXISTS (SELECT 1 FROM repositories r WHERE r.org = a.org AND r.slug = a.slug)
+) n
+WHERE p.repository_id IS NULL AND n.org = p.repository_owner AND n.slug = p.repository_name`, table)); err != nil {
+			return 0, wrapErr(err)
+		}
+	}
+
+	if _, err = tx.Exec(`INSERT INTO repository_aliases (repository_id, org, slug)
+SELECT DISTINCT p.repository_id, p.repository_owner, p.repository_name FROM prs p
+INNER JOIN repositories r ON r.id = p.repository_id
+WHERE (p.repository_owner, p.repository_name) <> (r.org, r.slug)
+ON CONFLICT (org, slug) DO UPDATE SET repository_id = EXCLUDED.repository_id`); err != nil {
+		return 0, wrapErr(err)
+	}
+
+	if _, err = tx.Exec(`UPDATE workflow_runs w SET repository_owner = r.org, repository_name = r.slug FROM repositories r
+WHERE w.repository_id = r.id AND (w.repository_owner, w.repository_name) <> (r.org, r.slug)`); err != nil {
+		return 0, wrapErr(err)
+	}
+
+	res, err := tx.Exec(`UPDATE prs p SET repository_owner = r.org, repository_name = r.slug FROM repositories r
+WHERE p.repository_id = r.id AND (p.repository_owner, p.repository_name) <> (r.org, r.slug)`)
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+
+	renamed, err := res.RowsAffected()
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+
+	return renamed, wrapErr(tx.Commit())
+}
+
+// GetUnlinkedRepos returns the repositories of the pull requests stored before ids were tracked whose name
+// is neither a current repository nor a known alias, they were renamed or transferred before that.
+func (p *Postgres) GetUnlinkedRepos() ([]DBRepository, error) {
+	repos := []DBRepository{}
+	err := p.db.Select(&repos, `SELECT DISTINCT p.repository_owner AS org, p.repository_name AS slug, '' AS language FROM prs p
+WHERE p.repository_id IS NULL
+AND NOT EXISTS (SELECT 1 FROM repositories r WHERE r.org = p.repository_owner AND r.slug = p.repository_name)
+AND NOT EXISTS (SELECT 1 FROM repository_aliases a WHERE a.org = p.repository_owner AND a.slug = p.repository_name)`)
+
+	return repos, wrapErr(err)
+}
+
+// SaveRepositoryAlias records a previous name of the repository.
+func (p *Postgres) SaveRepositoryAlias(id string, org string, slug string) error {
+	_, err := p.db.Exec(`INSERT INTO repository_aliases (repository_id, org, slug) VALUES ($1, $2, $3)
+ON CONFLICT (org, slug) DO UPDATE SET repository_id = EXCLUDED.repository_id`, id, org, slug)
+
+	return wrapErr(err)
+}
+
 func (p *Postgres) GetAllRepos() ([]DBRepository, error) {
 	repos := []DBRepository{}
-	if err := p.db.Select(&repos, "SELECT * FROM repositories"); err != nil {
+	if err := p.reader().Select(&repos, "SELECT org, slug, language FROM repositories WHERE NOT archived"); err != nil {
 		p.Logger.Error("can't fetch repositories", "error", err)
-		return nil, err
+		return nil, wrapErr(err)
 	}
 
 	return repos, nil
@@ -212,7 +700,7 @@ func (p *Postgres) SaveTeams(teams map[string][]string) error {
                               VALUES (:team, :member)`, batchUpdate)
 		if err != nil {
 			p.Logger.Error("can't insert new repository", "error", err)
-			return err
+			return wrapErr(err)
 		}
 	}
 
@@ -220,19 +708,20 @@ func (p *Postgres) SaveTeams(teams map[string][]string) error {
 }
 
 /**
-* Fetch the yesterday's pull requests
+* Fetch the yesterday's pull requests matching the security rules
  */
-func (p *Postgres) FetchSecurityPullRequests() ([]SecurityPR, error) {
+func (p *Postgres) FetchSecurityPullRequests(rules SecurityRules) ([]SecurityPR, error) {
 	prs := []SecurityPR{}
-	err := p.db.Select(&prs, `select p.id, p.url, p.title, p.repository_name, p.repository_owner, p.author, p.additions, p.deletions, state, created_at, merged_at
-from teams t 
-inner join prs p ON p.author = t.member 
-where (created_at >= date_trunc('day', current_timestamp) - interval '1 day' and p.state = 'OPEN') or (merged_at >= date_trunc('day', current_timestamp) - interval '1 day' and p.state = 'MERGED') 
-and t.team in ('pe-customer-journey', 'PE Platform Insights', 'Webstack', 'Omnibus', 'CSI', 'pe-platform-fleet', 'ie-deploy', 'P&E - Team Domino', 'Ares', 'RD-Edge', 'Golden', 'RD - Production Engineering', 'Security Engineering')
-group by p.id order by additions + deletions DESC`)
+	err := p.reader().Select(&prs, `select p.id, p.url, p.title, p.repository_name, p.repository_owner, p.author, p.additions, p.deletions, state, created_at, merged_at,
+array_remove(array_agg(DISTINCT t.team), NULL) AS teams
+from prs p
+left join teams t ON p.author = t.member
+where ((created_at >= date_trunc('day', current_timestamp) - interval '1 day' and p.state = 'OPEN') or (merged_at >= date_trunc('day', current_timestamp) - interval '1 day' and p.state = 'MERGED'))
+and (t.team = ANY($1) or p.labels && $2 or p.branch_name LIKE ANY($3) or p.title ~* ANY($4) or ($5 and p.author = 'dependabot'))
+group by p.id order by additions + deletions DESC`, pq.Array(rules.Teams), pq.Array(rules.Labels), pq.Array(rules.branchPatterns()), pq.Array(rules.TitleRegexes), rules.DependabotAll)
 	if err != nil {
 		p.Logger.Error("can't fetch security pull requests", "error", err)
-		return nil, err
+		return nil, wrapErr(err)
 	}
 
 	return prs, nil
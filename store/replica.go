@@ -0,0 +1,75 @@
+package store
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/akawula/DoraMatic/internal/config"
+	"github.com/jmoiron/sqlx"
+)
+
+// lagTTL is how long a lag check is trusted, so the reads don't each pay for a lag query first.
+const lagTTL = 5 * time.Second
+
+// replica is a read-only standby used by the QueryStore methods.
+type replica struct {
+	db     *sqlx.DB
+	maxLag time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	usable    bool
+}
+
+// newReplica connects to the configured replica, it returns nil when no replica is configured or it's unreachable.
+func newReplica(cfg config.Postgres, logger *slog.Logger) *replica {
+	if len(cfg.ReplicaHost) == 0 {
+		return nil
+	}
+
+	db, err := sqlx.Connect("postgres", connectionString(cfg, cfg.ReplicaHost, cfg.ReplicaPort))
+	if err != nil {
+		logger.Error("can't connect to postgres replica, reads will use the primary", "error", err)
+		return nil
+	}
+
+	return &replica{db: db, maxLag: cfg.ReplicaMaxLag}
+}
+
+// lag returns how far behind the primary the replica is, a fully replayed replica has no lag even when the primary is idle.
+func (r *replica) lag() (time.Duration, error) {
+	var seconds float64
+	err := r.db.Get(&seconds, `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END`)
+
+	return time.Duration(seconds * float64(time.Second)), err
+}
+
+// check reports whether the replica can serve reads, the lag is queried again once the last check is older than lagTTL.
+func (r *replica) check(logger *slog.Logger) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) < lagTTL {
+		return r.usable
+	}
+
+	lag, err := r.lag()
+	r.checkedAt, r.usable = time.Now(), err == nil && lag <= r.maxLag
+	if err != nil {
+		logger.Warn("can't check replica lag, falling back to primary", "error", err)
+	} else if lag > r.maxLag {
+		logger.Warn("replica is lagging, falling back to primary", "lag", lag, "maxLag", r.maxLag)
+	}
+
+	return r.usable
+}
+
+// reader returns the replica for read queries unless it's missing, unhealthy or lagging too much.
+func (p *Postgres) reader() *sqlx.DB {
+	if p.replica == nil || !p.replica.check(p.Logger) {
+		return p.db
+	}
+
+	return p.replica.db
+}
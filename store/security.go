@@ -0,0 +1,51 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akawula/DoraMatic/internal/config"
+)
+
+// SecurityRules decides which pull requests end up in the security digest, a pull request matching any rule is included.
+type SecurityRules struct {
+	Teams          []string // authored by a member of one of the teams
+	Labels         []string // carries one of the labels
+	BranchPrefixes []string // head branch starts with one of the prefixes
+	TitleRegexes   []string // title matches one of the (case insensitive, POSIX) regexes
+	DependabotAll  bool     // opened by dependabot, security or version update alike
+}
+
+func NewSecurityRules(cfg config.Security) SecurityRules {
+	return SecurityRules(cfg)
+}
+
+// branchPatterns turns the branch prefixes into LIKE patterns.
+func (r SecurityRules) branchPatterns() []string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	patterns := []string{}
+	for _, prefix := range r.BranchPrefixes {
+		patterns = append(patterns, replacer.Replace(prefix)+"%")
+	}
+
+	return patterns
+}
+
+// ValidateSecurityRules checks the title regexes with Postgres, its regex syntax isn't Go's; a broken regex would fail
+// the whole query, so the returned rules keep only the valid ones and the broken ones are in the error.
+func (p *Postgres) ValidateSecurityRules(rules SecurityRules) (SecurityRules, error) {
+	errs := []error{}
+	valid := []string{}
+	for _, re := range rules.TitleRegexes {
+		var matched bool
+		if err := p.reader().Get(&matched, `SELECT '' ~* $1`, re); err != nil {
+			errs = append(errs, fmt.Errorf("%w: SECURITY_PR_TITLE_REGEXES %q: %w", ErrInvalidArgument, re, err))
+			continue
+		}
+		valid = append(valid, re)
+	}
+	rules.TitleRegexes = valid
+
+	return rules, errors.Join(errs...)
+}